@@ -0,0 +1,58 @@
+package ham
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const basePlaceholder = "{ham:base}"
+
+// linkAttrs are the attributes rewriteRootLinks prefixes with the base path
+var linkAttrs = map[string]bool{"href": true, "src": true, "srcset": true, "action": true, "poster": true}
+
+// normalizeBasePath returns p as /docs: with a leading slash and without a trailing one. the root is ""
+func normalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// url prefixes a root relative url with the base path of the site. other urls are returned as they are
+func (c *Compiler) url(u string) string {
+	base := c.config.BasePath
+	if base == "" || !strings.HasPrefix(u, "/") || strings.HasPrefix(u, "//") {
+		return u
+	}
+	if u == base || strings.HasPrefix(u, base+"/") {
+		return u // already under the base path
+	}
+	return base + u
+}
+
+// rewriteRootLinks prefixes the root relative links of a page with the base path, so pages written for the root of a
+// domain work under a subdirectory
+func (c *Compiler) rewriteRootLinks(doc *html.Node) {
+	walkNodes(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		for i, a := range n.Attr {
+			if !linkAttrs[a.Key] || a.Namespace != "" {
+				continue
+			}
+			if a.Key != "srcset" {
+				n.Attr[i].Val = c.url(strings.TrimSpace(a.Val))
+				continue
+			}
+			candidates := strings.Split(a.Val, ",")
+			for j, candidate := range candidates {
+				candidate = strings.TrimSpace(candidate)
+				candidates[j] = c.url(candidate)
+			}
+			n.Attr[i].Val = strings.Join(candidates, ", ")
+		}
+	})
+}
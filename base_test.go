@@ -0,0 +1,33 @@
+package ham
+
+import "testing"
+
+func TestBasePath(t *testing.T) {
+	src := testFS(map[string]string{
+		"ham.json":       `{"basePath": "docs/", "rewriteLinks": true}`,
+		"src/index.html": `<a href="/about.html">about</a><a href="{ham:base}/faq/">faq</a><img src="/img/a.png" srcset="/img/a.png 1x, /img/b.png 2x"><a href="https://example.com/">out</a><a href="/docs/already/">in</a>`,
+		"src/index.css":  ``,
+	})
+	c, _ := testSite(t, src, Options{})
+
+	doc := renderDoc(t, c, "src/index.html")
+	if got := links(doc); !equal(got, "about /docs/about.html", "faq /docs/faq/", "out https://example.com/", "in /docs/already/") {
+		t.Errorf("base path failed: expected root relative links under /docs but got %q", got)
+	}
+	img := query(doc, "img")
+	if got := attrValues(img, "src"); !equal(got, "/docs/img/a.png") {
+		t.Errorf("base path failed: expected the image under /docs but got %q", got)
+	}
+	if got := attrValues(img, "srcset"); !equal(got, "/docs/img/a.png 1x, /docs/img/b.png 2x") {
+		t.Errorf("base path failed: expected the srcset under /docs but got %q", got)
+	}
+
+	c, _ = testSite(t, src, Options{BasePath: "/v2"})
+	b, err := c.RenderString(`<embed type="ham/layout-css"/><p>page</p>`, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if got := attrValues(query(parseDoc(t, b), "link[rel=stylesheet]"), "href"); !equal(got, "/v2/assets/css/index.css") {
+		t.Errorf("base path failed: expected the stylesheet under /v2 but got %q", got)
+	}
+}
@@ -0,0 +1,153 @@
+package ham
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+type ChangeKind string
+
+const (
+	ChangeCreate ChangeKind = "create"
+	ChangeUpdate ChangeKind = "change"
+	ChangeDelete ChangeKind = "delete"
+)
+
+// Change describes a file that a build created, changed or deleted (or would have, on a dry run).
+// output files are prefixed with the output directory, project files are relative to the project root
+type Change struct {
+	Path string
+	Kind ChangeKind
+}
+
+func (c *Compiler) addChange(path string, kind ChangeKind) {
+	for _, ch := range c.changes {
+		if ch.Path == path {
+			return
+		}
+	}
+	c.changes = append(c.changes, Change{Path: path, Kind: kind})
+}
+
+// writePage writes a compiled page to the output directory, recording whether it is new or changed.
+// on a dry run nothing is written and, when requested, a diff against the existing page is printed
+func (c *Compiler) writePage(pageFileName string, content []byte) error {
+	if c.built[pageFileName] {
+		return fmt.Errorf("failed to compile. %s is written by more than one page, check their permalinks", pageFileName)
+	}
+	c.built[pageFileName] = true
+
+	existing, err := c.out.ReadFile(pageFileName)
+	switch {
+	case err != nil:
+		c.addChange(c.outputPath(pageFileName), ChangeCreate)
+	case !bytes.Equal(existing, content):
+		c.addChange(c.outputPath(pageFileName), ChangeUpdate)
+		if c.opts.Diff {
+			name := c.outputPath(pageFileName)
+			fmt.Fprint(c.opts.Out, unifiedDiff("a/"+name, "b/"+name, existing, content))
+		}
+	default:
+		c.unchanged++
+	}
+
+	if c.opts.DryRun {
+		return nil
+	}
+	return c.out.WriteFile(pageFileName, content)
+}
+
+// pruneStalePages deletes pages produced by the previous build which no longer have a source page, when
+// Options.Prune asks for it. only pages listed in the build manifest are ever deleted, hand placed files in the output
+// directory are left alone. the manifest is kept in .ham-cache of the project, not in the output that is deployed
+func (c *Compiler) pruneStalePages() error {
+	previous := c.previousPages()
+	_, legacyErr := c.out.ReadFile(legacyManifestFile)
+
+	var stale []string // pages left in the output, listed again so a later build can still prune them
+	for _, pageFileName := range previous {
+		if c.built[pageFileName] {
+			continue
+		}
+		if _, err := c.out.ReadFile(pageFileName); err != nil {
+			continue
+		}
+		if !c.opts.Prune {
+			stale = append(stale, pageFileName)
+			continue
+		}
+		c.addChange(c.outputPath(pageFileName), ChangeDelete)
+		if c.opts.DryRun {
+			continue
+		}
+		log.Println("Deleting stale page: " + pageFileName)
+		if err := c.out.Remove(pageFileName); err != nil {
+			return err
+		}
+	}
+
+	if c.opts.DryRun || c.traceLinks {
+		return nil
+	}
+	if legacyErr == nil {
+		if err := c.out.Remove(legacyManifestFile); err != nil {
+			return err
+		}
+	}
+
+	current := stale
+	for pageFileName := range c.built {
+		current = append(current, pageFileName)
+	}
+	sort.Strings(current)
+	c.manifest = current
+	if c.workingDir == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifest := filepath.Join(c.workingDir, filepath.FromSlash(manifestFile))
+	if err := os.MkdirAll(filepath.Dir(manifest), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(manifest, b, 0644)
+}
+
+// previousPages returns the pages listed in the build manifest, the pages ham wrote to the output before this build
+func (c *Compiler) previousPages() []string {
+	previous := c.manifest
+	if c.workingDir != "" {
+		if b, err := os.ReadFile(filepath.Join(c.workingDir, filepath.FromSlash(manifestFile))); err == nil {
+			if err := json.Unmarshal(b, &previous); err != nil {
+				log.Println("ignoring unreadable build manifest", err.Error())
+			}
+		}
+	}
+	// builds before the manifest moved to .ham-cache wrote it to the output
+	if legacy, err := c.out.ReadFile(legacyManifestFile); err == nil && previous == nil {
+		json.Unmarshal(legacy, &previous)
+	}
+	return previous
+}
+
+func (c *Compiler) printChanges() {
+	counts := make(map[ChangeKind]int)
+	for _, ch := range c.changes {
+		counts[ch.Kind]++
+		fmt.Fprintf(c.opts.Out, "%-7s %s\n", ch.Kind, ch.Path)
+	}
+	fmt.Fprintf(c.opts.Out, "dry run: %d to create, %d to change, %d to delete, %d unchanged\n",
+		counts[ChangeCreate], counts[ChangeUpdate], counts[ChangeDelete], c.unchanged)
+}
+
+func (c *Compiler) outputPath(name string) string {
+	return path.Join(c.outputName, name)
+}
@@ -0,0 +1,47 @@
+package ham
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPruneStalePages(t *testing.T) {
+	src := testFS(map[string]string{
+		"src/index.html": `<p>home</p>`,
+		"src/old.html":   `<p>old</p>`,
+	})
+	c, out := testSite(t, src, Options{})
+	out.WriteFile("robots.txt", []byte("User-agent: *"))
+	if err := c.Compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if want := "index.html old.html robots.txt"; strings.Join(out.Files(), " ") != want {
+		t.Errorf("prune failed: expected %s in the output but got %v", want, out.Files())
+	}
+
+	delete(src, "src/old.html")
+	if err := c.Compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if _, err := out.ReadFile("old.html"); err != nil {
+		t.Errorf("prune failed: expected a build without Prune to keep old.html")
+	}
+
+	var report bytes.Buffer
+	c.opts.Prune, c.opts.DryRun, c.opts.Out = true, true, &report
+	if err := c.Compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if _, err := out.ReadFile("old.html"); err != nil || !strings.Contains(report.String(), "delete  old.html") {
+		t.Errorf("prune failed: expected a dry run to list old.html but got\n%s", report.String())
+	}
+
+	c.opts.DryRun = false
+	if err := c.Compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if want := "index.html robots.txt"; strings.Join(out.Files(), " ") != want {
+		t.Errorf("prune failed: expected %s in the output but got %v", want, out.Files())
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/fobilow/detach"
 	"github.com/fobilow/ham"
+	"github.com/fobilow/ham/helper"
 	"github.com/fobilow/ham/proxy"
 )
 
@@ -25,8 +26,23 @@ func main() {
 	h := ham.NewSite()
 	newCmd := newFlagSet(h, "init")
 	buildCmd := newFlagSet(h, "build")
+	fmtCmd := newFlagSet(h, "fmt")
+	pageCmd := newFlagSet(h, "new")
+	checkCmd := newFlagSet(h, "check")
 
 	bwd := buildCmd.String("w", "./", "working directory")
+	dryRun := buildCmd.Bool("dry-run", false, "compile without writing any files")
+	diff := buildCmd.Bool("diff", false, "show a diff of every page that would change")
+	prune := buildCmd.Bool("prune", false, "delete the pages of the previous build whose source page is gone")
+	env := buildCmd.String("env", helper.GetEnv("HAM_ENV", ""), "environment whose ham.json settings apply")
+	basePath := buildCmd.String("base-path", "", "directory the site is served under, e.g. /docs. overrides basePath of ham.json")
+	fwd := fmtCmd.String("w", "./", "working directory")
+	pwd := pageCmd.String("w", "./", "working directory")
+	cwd := checkCmd.String("w", "./", "working directory")
+	checkEnv := checkCmd.String("env", helper.GetEnv("HAM_ENV", ""), "environment whose ham.json settings apply")
+	checkBasePath := checkCmd.String("base-path", "", "directory the site is served under, e.g. /docs. overrides basePath of ham.json")
+	ignore := checkCmd.String("ignore", "", "comma separated url prefixes not to check, e.g. /api/")
+	external := checkCmd.Bool("external", false, "list the links to other sites")
 
 	command := ""
 	if len(os.Args) > 1 {
@@ -48,6 +64,18 @@ func main() {
 			return
 		}
 		checkError(h.NewProject(name, getWorkingDir("./")))
+	case "new":
+		if len(os.Args) < 3 || os.Args[2] != "page" {
+			pageCmd.Usage()
+			return
+		}
+		checkError(pageCmd.Parse(os.Args[3:]))
+		if pageCmd.NArg() == 0 {
+			fmt.Println("please provide the path of the page, e.g. blog/post")
+			pageCmd.Usage()
+			return
+		}
+		checkError(h.NewPage(getWorkingDir(*pwd), pageCmd.Arg(0)))
 	case "build":
 		checkError(buildCmd.Parse(os.Args[2:]))
 		if len(*bwd) == 0 {
@@ -55,7 +83,38 @@ func main() {
 			buildCmd.Usage()
 			return
 		}
-		checkError(h.Build(getWorkingDir(*bwd), ham.DefaultOutputDir))
+		checkError(h.BuildWithOptions(getWorkingDir(*bwd), ham.DefaultOutputDir, ham.Options{DryRun: *dryRun, Diff: *diff, Prune: *prune, Env: *env, BasePath: *basePath}))
+	case "check":
+		if len(os.Args) < 3 || os.Args[2] != "links" {
+			checkCmd.Usage()
+			return
+		}
+		checkError(checkCmd.Parse(os.Args[3:]))
+		var prefixes []string
+		if *ignore != "" {
+			prefixes = strings.Split(*ignore, ",")
+		}
+		report, err := h.CheckLinks(getWorkingDir(*cwd), ham.DefaultOutputDir, ham.Options{Env: *checkEnv, BasePath: *checkBasePath}, prefixes)
+		checkError(err)
+		for _, link := range report.Broken {
+			fmt.Println(link)
+		}
+		if *external {
+			for _, link := range report.External {
+				fmt.Println("external", link)
+			}
+		}
+		fmt.Printf("checked %d links on %d pages: %d broken, %d external\n", report.Links, report.Pages, len(report.Broken), len(report.External))
+		if len(report.Broken) > 0 {
+			os.Exit(1)
+		}
+	case "fmt":
+		checkError(fmtCmd.Parse(os.Args[2:]))
+		changed, err := h.Format(getWorkingDir(*fwd))
+		checkError(err)
+		for _, file := range changed {
+			fmt.Println("formatted", file)
+		}
 	case "proxy":
 		proxy.Run()
 	case "version":
@@ -0,0 +1,241 @@
+package ham
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CollectionConfig defines a collection in ham.json, e.g.
+// "collections": {"blog": {"glob": "src/blog/*.md", "sortBy": "date", "order": "desc"}}
+type CollectionConfig struct {
+	Glob   string      `json:"glob"`             // source pages of the collection, relative to the project root
+	SortBy string      `json:"sortBy,omitempty"` // front matter key the items are sorted by. defaults to date
+	Order  string      `json:"order,omitempty"`  // asc or desc. defaults to desc
+	Feed   *FeedConfig `json:"feed,omitempty"`   // write RSS, Atom and JSON feeds of the collection
+}
+
+// collectionItem is a page of a collection
+type collectionItem struct {
+	SrcPath string
+	URL     string // root relative url of the compiled page
+	Title   string
+	Date    time.Time
+	Summary string
+	Content string // page content as html, without its layout
+	Data    map[string]interface{}
+
+	items []*collectionItem // items of a taxonomy term
+}
+
+// reservedItemKeys are set by ham for the item partial, a front matter value of the same name would be hidden
+var reservedItemKeys = []string{"content", "url"}
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// collection returns the items of a collection, sorted as configured. collections are read once per build
+func (c *Compiler) collection(name string) ([]*collectionItem, error) {
+	c.mu.Lock()
+	items, ok := c.collections[name]
+	c.mu.Unlock()
+	if ok {
+		return items, nil
+	}
+	conf, ok := c.config.Collections[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown collection %s", name)
+	}
+
+	files, err := fs.Glob(c.src, conf.Glob)
+	if err != nil {
+		return nil, fmt.Errorf("collection %s: %v", name, err)
+	}
+	for _, file := range files {
+		item, err := c.collectionItem(file)
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %v", name, err)
+		}
+		items = append(items, item)
+	}
+
+	sortBy := conf.SortBy
+	if sortBy == "" {
+		sortBy = "date"
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		less := lessValue(items[i], items[j], sortBy)
+		if conf.Order == "asc" {
+			return less
+		}
+		return lessValue(items[j], items[i], sortBy)
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.collections == nil {
+		c.collections = make(map[string][]*collectionItem)
+	}
+	c.collections[name] = items
+	return items, nil
+}
+
+func (c *Compiler) collectionItem(file string) (*collectionItem, error) {
+	data, body, err := parseFrontMatter(c.readFile(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	for _, key := range reservedItemKeys {
+		if _, ok := data[key]; ok {
+			return nil, fmt.Errorf("%s: front matter key %s is reserved, the item partial gets __%s__ from ham", file, key, key)
+		}
+	}
+	if path.Ext(file) == ".md" {
+		if body, err = markdownToHTML(body); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	outPath, err := c.pageOutPath(file, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	item := &collectionItem{
+		SrcPath: file,
+		URL:     c.url("/" + pageURLPath(outPath)),
+		Title:   stringValue(data["title"]),
+		Date:    parseDate(data["date"]),
+		Summary: stringValue(data["description"]),
+		Content: string(bytes.TrimSpace(body)),
+		Data:    data,
+	}
+	if item.Title == "" {
+		item.Title = strings.TrimSuffix(path.Base(file), path.Ext(file))
+	}
+	if item.Summary == "" {
+		item.Summary = stringValue(data["summary"])
+	}
+	return item, nil
+}
+
+// lessValue compares two items by a front matter key. dates compare as dates, numbers as numbers, anything else as text
+func lessValue(a, b *collectionItem, key string) bool {
+	if key == "date" {
+		return a.Date.Before(b.Date)
+	}
+	av, bv := a.Data[key], b.Data[key]
+	if af, ok := av.(float64); ok {
+		if bf, ok := bv.(float64); ok {
+			return af < bf
+		}
+	}
+	return stringValue(av) < stringValue(bv)
+}
+
+// collectionEmbed renders the item partial of a ham/collection embed once per item
+//
+//	<embed type="ham/collection" data-collection="blog" src="post-summary.phtml" data-limit="5"/>
+//
+// data-collection names a collection, data.<name> or taxonomies.<name> of ham.json. on a paginated or generated page
+// it defaults to the page's items.
+// the partial gets the item in __title__, __url__, __date__, __summary__ and __content__ and every other front matter
+// value in __key__, content and url are reserved. dates are written as 2006-01-02 unless data-date-format holds
+// another Go time layout
+func (c *Compiler) collectionEmbed(embed Embed, ctx *PageContext, relativeTo string) ([]byte, error) {
+	var items []*collectionItem
+	if p := ctx.Pagination; p != nil && (embed.Attrs["data-collection"] == "" || sameData(embed.Attrs["data-collection"], ctx.Page.Layout.Paginate.Data)) {
+		items = p.items // the items of the current page of a paginated listing
+	} else if ctx.items != nil && embed.Attrs["data-collection"] == "" {
+		items = ctx.items // the items of a generated page, e.g. the posts of a tag
+	} else {
+		var err error
+		if items, err = c.dataItems(embed.Attrs["data-collection"]); err != nil {
+			return nil, fmt.Errorf("failed to compile %s. %v", ctx.SrcPath, err)
+		}
+	}
+	if limit, err := strconv.Atoi(embed.Attrs["data-limit"]); err == nil && limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+
+	partialPath := path.Join(path.Dir(relativeTo), embed.Src)
+	partial := c.readFile(partialPath)
+	if partial == nil {
+		return nil, fmt.Errorf("failed to compile %s. collection item partial %s not found", ctx.SrcPath, partialPath)
+	}
+	dateFormat := embed.Attrs["data-date-format"]
+	if dateFormat == "" {
+		dateFormat = "2006-01-02"
+	}
+
+	buf := &bytes.Buffer{}
+	for _, item := range items {
+		buf.Write(renderItem(partial, item, dateFormat))
+	}
+	return buf.Bytes(), nil
+}
+
+// sameData reports whether two data references, e.g. blog and collections.blog, name the same items
+func sameData(a, b string) bool {
+	return strings.TrimPrefix(a, "collections.") == strings.TrimPrefix(b, "collections.")
+}
+
+// renderItem fills the replacement keys of an item partial
+func renderItem(partial []byte, item *collectionItem, dateFormat string) []byte {
+	values := map[string]string{}
+	for key, val := range item.Data {
+		values[key] = html.EscapeString(stringValue(val))
+	}
+	values["title"] = html.EscapeString(item.Title)
+	values["url"] = html.EscapeString(item.URL)
+	values["summary"] = html.EscapeString(item.Summary)
+	values["content"] = item.Content
+	values["date"] = ""
+	if !item.Date.IsZero() {
+		values["date"] = item.Date.Format(dateFormat)
+	}
+
+	var pairs []string
+	for key, val := range values {
+		pairs = append(pairs, embedReplaceKey(key), val)
+	}
+	return []byte(strings.NewReplacer(pairs...).Replace(string(partial)))
+}
+
+func stringValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			parts = append(parts, stringValue(item))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+func parseDate(v interface{}) time.Time {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
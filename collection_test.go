@@ -0,0 +1,44 @@
+package ham
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCollections(t *testing.T) {
+	src := testFS(map[string]string{
+		"ham.json":            `{"collections": {"blog": {"glob": "src/blog/*.md"}}}`,
+		"src/blog/first.md":   "---\ntitle: First post\ndate: 2024-01-05\ntags: [go]\n---\nHello *world*\n",
+		"src/blog/second.md":  "---\ntitle: Second & last\ndate: 2024-02-01\ndescription: The second one\n---\nBye\n",
+		"src/blog/index.html": `<ul><embed type="ham/collection" data-collection="blog" src="item.phtml"/></ul>`,
+		"src/blog/item.phtml": `<li><a href="__url__">__title__</a> <time>__date__</time></li>`,
+	})
+	c, out := buildSite(t, src, Options{})
+
+	doc := readDoc(t, out, "blog/index.html")
+	links := query(doc, "a")
+	if got := attrValues(links, "href"); !equal(got, "/blog/second.html", "/blog/first.html") {
+		t.Errorf("collection listing failed: expected the newest page first but got %q", got)
+	}
+	if got := texts(links); !equal(got, "Second & last", "First post") {
+		t.Errorf("collection listing failed: expected the titles but got %q", got)
+	}
+	if got := texts(query(doc, "time")); !equal(got, "2024-02-01", "2024-01-05") {
+		t.Errorf("collection listing failed: expected the dates but got %q", got)
+	}
+
+	// a page rendered after the build lists the pages added since
+	src["src/blog/third.md"] = &fstest.MapFile{Data: []byte("---\ntitle: Third\ndate: 2024-03-01\n---\nNew\n")}
+	doc = renderDoc(t, c, "src/blog/index.html")
+	if got := attrValues(query(doc, "a"), "href"); !equal(got, "/blog/third.html", "/blog/second.html", "/blog/first.html") {
+		t.Errorf("collection listing failed: expected the new page after the build but got %q", got)
+	}
+
+	// front matter may not hide the values ham sets for the item partial
+	src["src/blog/third.md"] = &fstest.MapFile{Data: []byte("---\ntitle: Third\ncontent: summary\n---\nBody\n")}
+	c, _ = testSite(t, src, Options{})
+	if err := c.Compile(); err == nil || !strings.Contains(err.Error(), "src/blog/third.md: front matter key content is reserved") {
+		t.Errorf("collections failed: expected the content key to be an error but got %v", err)
+	}
+}
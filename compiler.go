@@ -3,45 +3,193 @@ package ham
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"golang.org/x/net/html"
 )
 
-const parseLimit = 1000 // max number of times to iterate and find partials inside partials
+const parseLimit = 1000 // max depth of partials inside partials
+const (
+	manifestFile       = ".ham-cache/manifest.json" // pages written by the last build of a project on disk
+	legacyManifestFile = ".ham-manifest"            // where builds used to put the manifest, in the output
+)
+
+// Options controls how a build is carried out
+type Options struct {
+	DryRun bool      // compile everything but write nothing, only report what would change
+	Diff   bool      // print a unified diff for every changed page. implies DryRun
+	Out    io.Writer // destination of dry-run and diff reports. defaults to os.Stdout
+	Env    string    // environment whose ham.json settings apply, e.g. production
+
+	BasePath string // overrides basePath of ham.json when set
+	Prune    bool   // delete the pages of the previous build whose source page is gone
+
+	// Plugins are available to this compiler only, in addition to the registered ones. they are enabled by listing
+	// their names under "plugins" in ham.json and take precedence over a registered plugin of the same name
+	Plugins []Plugin
+}
+
+// Compiler compiles a HAM project read from src into out.
+// all paths used by the compiler are slash separated and relative to the root of src, e.g. src/index.html
 type Compiler struct {
-	workingDir string
-	outputDir  string
-	pageHTML   []byte
-	layoutHTML []byte
+	src         fs.FS
+	out         Output
+	workingDir  string // set when src is a directory on disk
+	outputName  string // prefix used when reporting output files
+	opts        Options
+	config      Config
+	plugins     []Plugin
+	pages       []*PageContext               // pages written by the current build
+	mu          sync.Mutex                   // guards the caches below, RenderPage may be called concurrently
+	collections map[string][]*collectionItem // collections read by the current build, by name
+	integrity   map[string]string            // subresource integrity of the resources linked by the current build, by path
+	images      map[string]*processedImage   // images written by the current build, by project path
+	imageCache  map[string][]byte            // resized images, by source digest, width and format
+	traceLinks  bool                         // set by CheckLinks to record the partial or layout of every link
+	readCache   map[string][]byte
+	manifest    []string        // pages written by the last build, for projects that are not on disk
+	built       map[string]bool // output pages produced by the current build, nil outside of Compile
+	changes     []Change
+	unchanged   int
 }
 
 func New(workingDir, outputDir string) (*Compiler, error) {
-	if _, err := os.Stat(filepath.Join(workingDir, "ham.json")); err != nil {
+	return NewWithOptions(workingDir, outputDir, Options{})
+}
+
+func NewWithOptions(workingDir, outputDir string, opts Options) (*Compiler, error) {
+	if !filepath.IsAbs(outputDir) {
+		outputDir = filepath.Join(workingDir, outputDir)
+	}
+	c, err := NewFS(os.DirFS(workingDir), NewDirOutput(outputDir), opts)
+	if err != nil {
 		return nil, fmt.Errorf("%s  is not a valid HAM project", workingDir)
 	}
+	c.workingDir = workingDir
+	if rel, err := filepath.Rel(workingDir, outputDir); err == nil {
+		c.outputName = filepath.ToSlash(rel)
+	} else {
+		c.outputName = outputDir
+	}
 
-	return &Compiler{workingDir: workingDir, outputDir: outputDir}, nil
+	return c, nil
+}
+
+// NewFS creates a compiler that reads the project from src, e.g. an embed.FS, and writes to out.
+// src must contain ham.json at its root. use fs.Sub to compile a project embedded in a sub directory
+func NewFS(src fs.FS, out Output, opts Options) (*Compiler, error) {
+	if _, err := fs.Stat(src, configFileName); err != nil {
+		return nil, fmt.Errorf("%s not found, not a valid HAM project", configFileName)
+	}
+	config, err := loadConfig(src, opts.Env)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := enabledPlugins(config.Plugins, opts.Plugins)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Diff {
+		opts.DryRun = true
+	}
+	if opts.BasePath != "" {
+		config.BasePath = opts.BasePath
+	}
+	config.BasePath = normalizeBasePath(config.BasePath)
+	if config.Images != nil {
+		for _, name := range config.Images.Formats {
+			if _, ok := lookupImageFormat(name); !ok {
+				return nil, fmt.Errorf("invalid %s: image format %s is not registered. ham ships no encoder for it, "+
+					"a program using ham as a library can add one with RegisterImageFormat", configFileName, name)
+			}
+		}
+	}
+	if config.CSP != nil {
+		switch config.CSP.Mode {
+		case "", cspMeta, cspHeaders:
+		default:
+			return nil, fmt.Errorf("invalid %s: unknown csp mode %s, use %s or %s", configFileName, config.CSP.Mode, cspMeta, cspHeaders)
+		}
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+
+	return &Compiler{src: src, out: out, opts: opts, config: config, plugins: enabled, readCache: make(map[string][]byte),
+		imageCache: make(map[string][]byte)}, nil
 }
 
 func (c *Compiler) Compile() error {
-	// create output directory
-	if err := os.MkdirAll(c.outputDir, 0744); err != nil {
+	// pages rendered after the build, e.g. by RenderPage, must not write bundles or image variants, nor use the
+	// collections, digests and images of this build, the files they come from may change before the next one
+	c.built = make(map[string]bool)
+	c.clearBuildCaches()
+	defer func() {
+		c.built = nil
+		c.clearBuildCaches()
+	}()
+	c.changes = nil
+	c.unchanged = 0
+	c.pages = nil
+
+	if err := c.compilePages(srcDir); err != nil {
 		return err
 	}
 
-	if err := c.compilePages(srcDir); err != nil {
+	taxonomyPages, err := c.taxonomyPages()
+	if err != nil {
+		return err
+	}
+	if err := c.compileVirtualPages(taxonomyPages); err != nil {
+		return err
+	}
+
+	if err := c.writeFeeds(); err != nil {
+		return err
+	}
+
+	if err := c.writeHeaders(); err != nil {
 		return err
 	}
 
+	if err := c.writeSitemap(); err != nil {
+		return err
+	}
+
+	if err := c.pruneStalePages(); err != nil {
+		return err
+	}
+
+	for _, p := range c.plugins {
+		if hook, ok := p.(AfterBuildHook); ok {
+			if err := hook.AfterBuild(&BuildContext{Pages: c.pages, Output: c.out}); err != nil {
+				return fmt.Errorf("plugin %s: %v", p.Name(), err)
+			}
+		}
+	}
+
+	if c.opts.DryRun {
+		c.printChanges()
+	}
+
 	return nil
 }
 
+// Changes returns the files created, changed or deleted by the last call to Compile
+func (c *Compiler) Changes() []Change {
+	return c.changes
+}
+
 func (c *Compiler) compilePages(dir string) error {
-	pagesFiles, err := os.ReadDir(filepath.Join(c.workingDir, dir))
+	pagesFiles, err := fs.ReadDir(c.src, dir)
 	if err != nil {
 		return err
 	}
@@ -49,196 +197,496 @@ func (c *Compiler) compilePages(dir string) error {
 	for _, page := range pagesFiles {
 		pageName := page.Name()
 		if page.IsDir() {
-			if err := c.compilePages(filepath.Join(dir, pageName)); err != nil {
+			if err := c.compilePages(path.Join(dir, pageName)); err != nil {
 				return err
 			}
 			continue
 		}
 
 		// get file extension
-		if filepath.Ext(page.Name()) != ".html" {
+		switch path.Ext(page.Name()) {
+		case ".html", ".md":
+		default:
 			log.Println("skipping file: " + page.Name())
 			continue
 		}
 
-		srcFileName := filepath.Join(c.workingDir, dir, pageName)
-		pageDir := strings.Replace(dir, "src", "", 1)
-		pageFileName := filepath.Join(c.workingDir, "public", pageDir, pageName)
-		file, err := os.Open(srcFileName)
+		srcFileName := path.Join(dir, pageName)
+		ctx := &PageContext{SrcPath: srcFileName, permalink: true}
+		pageHTML, err := c.renderPage(ctx)
 		if err != nil {
 			return err
 		}
+		pageFileName := ctx.OutPath
+		if err := c.checkOutPath(ctx); err != nil {
+			return err
+		}
 
-		// parse dom
-		doc, err := html.Parse(file)
-		if err != nil {
+		// write final html to file
+		log.Println("Creating page: " + pageFileName + " from " + srcFileName)
+		if err := c.writePage(pageFileName, pageHTML); err != nil {
 			return err
 		}
+		c.pages = append(c.pages, ctx)
 
-		hasEmbeds := true
-		i := 0
-		for hasEmbeds && i < parseLimit {
-			doc, hasEmbeds, err = c.compile(doc, srcFileName)
+		// the other pages of a paginated listing
+		for n := 2; ctx.Pagination != nil && n <= ctx.Pagination.Total; n++ {
+			pageCtx := &PageContext{SrcPath: srcFileName, OutPath: paginatedOutPath(pageFileName, n), Pagination: &Pagination{Number: n}}
+			pageHTML, err := c.renderPage(pageCtx)
 			if err != nil {
 				return err
 			}
-			i++
+			if err := c.checkOutPath(pageCtx); err != nil {
+				return err
+			}
+			log.Println("Creating page: " + pageCtx.OutPath + " from " + srcFileName)
+			if err := c.writePage(pageCtx.OutPath, pageHTML); err != nil {
+				return err
+			}
+			c.pages = append(c.pages, pageCtx)
 		}
+	}
+	return nil
+}
 
-		// this should take care of any "ham-remove" found in embedded partials
-		c.compile(doc, srcFileName)
-
-		// write final html to file
-		log.Println("Creating page: " + pageFileName + " from " + srcFileName)
-		if err := os.MkdirAll(filepath.Dir(pageFileName), os.ModePerm); err != nil {
-			return err
-		}
-		if err := os.WriteFile(pageFileName, c.pageHTML, os.ModePerm); err != nil {
-			return err
+// checkOutPath fails the build when another page of the build is already written to the output file of ctx
+func (c *Compiler) checkOutPath(ctx *PageContext) error {
+	if !c.built[ctx.OutPath] {
+		return nil
+	}
+	for _, other := range c.pages {
+		if other.OutPath == ctx.OutPath {
+			return fmt.Errorf("failed to compile %s. %s is also written by %s, check their permalinks", ctx.SrcPath, ctx.OutPath, other.SrcPath)
 		}
-		c.Reset()
 	}
-	return nil
+	return nil // not a page, writePage reports it
 }
 
-func (c *Compiler) compile(doc *html.Node, pageFilePath string) (*html.Node, bool, error) {
-	page := ParsePage(doc)
+// RenderOptions controls how RenderString renders a page
+type RenderOptions struct {
+	// Path the page is rendered as, relative to the project root. its layout, partials and resources
+	// are resolved relative to this path. defaults to src/index.html
+	Path string
+}
 
-	pageCssFileName := strings.ReplaceAll(pageFilePath, ".html", ".css")
-	page.Layout.CSS = append(page.Layout.CSS, pageCssFileName)
+// RenderPage compiles a single page, e.g. src/about.html, with its layout and partials and returns the final html.
+// nothing is written to the output. project files are cached for the lifetime of the compiler.
+// pages may be rendered concurrently, e.g. by an http handler, but not while Compile or CheckLinks runs
+func (c *Compiler) RenderPage(pagePath string) ([]byte, error) {
+	return c.renderPage(&PageContext{SrcPath: pagePath})
+}
 
-	pageTsFileName := strings.ReplaceAll(pageFilePath, ".html", ".ts")
-	page.Layout.JsMod = append(page.Layout.JsMod, pageTsFileName)
+func (c *Compiler) renderPage(ctx *PageContext) ([]byte, error) {
+	src, err := fs.ReadFile(c.src, ctx.SrcPath)
+	if err != nil {
+		return nil, err
+	}
+	return c.render(ctx, src)
+}
 
-	log.Println("Resources", pageFilePath, page.Layout.CSS, page.Layout.Js, page.Layout.JsMod)
-	dedupe := make(map[string]bool)
-	pageResources := append([]string{}, page.Layout.CSS...)
-	pageResources = append(pageResources, page.Layout.Js...)
-	pageResources = append(pageResources, page.Layout.JsMod...)
+// RenderString compiles the page markup in pageHTML as if it was read from opts.Path
+func (c *Compiler) RenderString(pageHTML string, opts RenderOptions) ([]byte, error) {
+	if opts.Path == "" {
+		opts.Path = path.Join(srcDir, "index.html")
+	}
+	return c.render(&PageContext{SrcPath: opts.Path}, []byte(pageHTML))
+}
 
-	pageCSS := make([]string, len(page.Layout.CSS))
-	pageJs := make([]string, len(page.Layout.Js))
-	for _, res := range pageResources {
-		if !filepath.IsAbs(res) {
-			res = filepath.Join(filepath.Dir(pageFilePath), res) // re-adjust res path
+func (c *Compiler) render(ctx *PageContext, src []byte) ([]byte, error) {
+	ctx.embedCount = make(map[string]int)
+
+	var err error
+	for _, p := range c.plugins {
+		if hook, ok := p.(BeforeParseHook); ok {
+			if src, err = hook.BeforeParse(ctx, src); err != nil {
+				return nil, fmt.Errorf("plugin %s: %v", p.Name(), err)
+			}
 		}
-		if _, ok := dedupe[res]; ok {
-			continue
+	}
+
+	frontMatter, src, err := parseFrontMatter(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %s. %v", ctx.SrcPath, err)
+	}
+	if path.Ext(ctx.SrcPath) == ".md" {
+		if src, err = markdownToHTML(src); err != nil {
+			return nil, fmt.Errorf("failed to compile %s. %v", ctx.SrcPath, err)
 		}
-		dedupe[res] = true
-		if err := createFile(res, nil, false); err != nil {
-			log.Println("error writing css file", err.Error())
+	}
+
+	// parse dom
+	doc, err := html.Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	page := ParsePage(doc)
+	if err := applyFrontMatter(&page, frontMatter); err != nil {
+		return nil, fmt.Errorf("failed to compile %s. %v", ctx.SrcPath, err)
+	}
+	ctx.Page = &page
+	if ctx.permalink {
+		if ctx.OutPath, err = c.pageOutPath(ctx.SrcPath, page.Data); err != nil {
+			return nil, fmt.Errorf("failed to compile %s. %v", ctx.SrcPath, err)
 		}
-		i := strings.Index(res, filepath.Clean("/assets")) // make path os portable
-		if i >= 0 {
-			res = res[i:]
+	}
+	if page.Layout.Paginate != nil {
+		if err := c.paginate(ctx); err != nil {
+			return nil, err
 		}
-
-		switch filepath.Ext(res) {
-		case ".css":
-			d := filepath.Base(filepath.Dir(pageFilePath))
-			p := []string{"/", "assets", "css"}
-			if d != srcDir {
-				p = append(p, d)
-			}
-			p = append(p, filepath.Base(res))
-			res = filepath.Join(p...)
-			pageCSS = append(pageCSS, `<link rel="stylesheet" href="`+res+`">`)
-		case ".js":
-			d := filepath.Base(filepath.Dir(pageFilePath))
-			p := []string{"/", "assets", "js"}
-			if d != srcDir {
-				p = append(p, d)
+	}
+	for _, p := range c.plugins {
+		if hook, ok := p.(AfterParsePageHook); ok {
+			if err := hook.AfterParsePage(ctx, doc); err != nil {
+				return nil, fmt.Errorf("plugin %s: %v", p.Name(), err)
 			}
-			p = append(p, filepath.Base(res))
-			res = filepath.Join(p...)
-			pageJs = append(pageJs, `<script src="`+res+`"></script>`)
-		case ".ts":
-			d := filepath.Base(filepath.Dir(pageFilePath))
-			p := []string{"/", "assets", "js"}
-			if d != srcDir {
-				p = append(p, d)
+		}
+	}
+
+	if err := c.expandEmbeds(doc, page.Embeds, ctx, ctx.SrcPath, 0); err != nil {
+		return nil, err
+	}
+
+	head := pageHead(doc, &page)
+	if page.Layout.Src != "" {
+		if doc, err = c.applyLayout(doc, ctx); err != nil {
+			return nil, err
+		}
+	}
+	mergeHead(findElement(doc, "head"), head)
+
+	var layoutCSS []Resource
+	if c.config.BundleCSS {
+		layoutCSS = c.layoutStylesheets(doc)
+	}
+	headResources, bodyResources, err := c.pageResources(ctx, layoutCSS)
+	if err != nil {
+		return nil, err
+	}
+	if err := replacePlaceholders(doc, "{ham:css}", []byte(strings.Join(headResources, "\n"))); err != nil {
+		return nil, err
+	}
+	if err := replacePlaceholders(doc, "{ham:js}", []byte(strings.Join(bodyResources, "\n"))); err != nil {
+		return nil, err
+	}
+	ensureDoctype(doc)
+	applyPaginationIf(doc, ctx.Pagination)
+	if c.config.Images != nil {
+		if err := c.processImages(doc, ctx); err != nil {
+			return nil, err
+		}
+	}
+	if c.config.RewriteLinks {
+		c.rewriteRootLinks(doc)
+	}
+	walkNodes(doc, func(n *html.Node) {
+		if n.Type == html.ElementNode && attr(n, "data-ham-proxy") == authAttrValue {
+			ctx.requiresAuth = true
+		}
+	})
+
+	for _, p := range c.plugins {
+		if hook, ok := p.(AfterLayoutHook); ok {
+			if err := hook.AfterLayout(ctx, doc); err != nil {
+				return nil, fmt.Errorf("plugin %s: %v", p.Name(), err)
 			}
-			p = append(p, filepath.Base(res))
-			res = filepath.Join(p...)
-			res = strings.Replace(res, ".ts", ".js", 1)
-			pageJs = append(pageJs, `<script type="module" src="`+res+`"></script>`)
 		}
 	}
 
 	buf := &bytes.Buffer{}
 	if err := html.Render(buf, doc); err != nil {
-		return nil, false, err
+		return nil, err
 	}
+	pageHTML := replacePagination(buf.Bytes(), ctx.Pagination)
+	pageHTML = bytes.ReplaceAll(pageHTML, []byte(basePlaceholder), []byte(c.config.BasePath))
 
-	c.pageHTML = make([]byte, buf.Len())
-	copy(c.pageHTML, buf.Bytes())
+	switch {
+	case c.config.Minify:
+		if pageHTML, err = minifyHTML(pageHTML); err != nil {
+			return nil, err
+		}
+	case c.config.Pretty:
+		if pageHTML, err = formatHTML(pageHTML); err != nil {
+			return nil, err
+		}
+	}
 
-	if c.layoutHTML == nil && page.Layout.Src != "" {
-		layoutFilePath := filepath.Join(filepath.Dir(pageFilePath), page.Layout.Src)
-		if _, err := os.Stat(layoutFilePath); err != nil {
-			return nil, false, fmt.Errorf("failed to compile %s. Layout file %s not found", pageFilePath, layoutFilePath)
+	for _, p := range c.plugins {
+		if hook, ok := p.(BeforeWriteHook); ok {
+			if pageHTML, err = hook.BeforeWrite(ctx, pageHTML); err != nil {
+				return nil, fmt.Errorf("plugin %s: %v", p.Name(), err)
+			}
 		}
-		log.Printf("Compiling Page: %s with %s\n", pageFilePath, layoutFilePath)
+	}
 
-		c.layoutHTML = readFile(layoutFilePath)
-		lDoc, err := html.Parse(bytes.NewBuffer(c.layoutHTML))
+	if c.config.CSP != nil {
+		policy, err := c.pageCSP(pageHTML, ctx)
 		if err != nil {
-			return nil, false, err
+			return nil, fmt.Errorf("failed to compile %s. %v", ctx.SrcPath, err)
 		}
-
-		layout := ParseLayout(lDoc)
-		layout.Path = layoutFilePath
-		buf.Reset()
-		if err := html.Render(buf, lDoc); err != nil {
-			return nil, false, err
+		if c.config.CSP.Mode == cspHeaders {
+			ctx.csp = policy
+		} else if policy != "" {
+			pageHTML = insertCSPMeta(pageHTML, policy)
 		}
+	}
 
-		c.layoutHTML = buf.Bytes()
-		c.pageHTML = bytes.Replace(c.pageHTML, []byte("<html><head></head><body>"), []byte(""), 1) // strip out <html><head></head><body>
-		c.pageHTML = bytes.Replace(c.pageHTML, []byte("</body></html>"), []byte(""), 1)            // strip out </body></html>
-		c.pageHTML = bytes.Replace(c.layoutHTML, []byte("{ham:page}"), c.pageHTML, 1)
+	return pageHTML, nil
+}
 
-		// find and replace layout embeds
-		for _, embed := range layout.Embeds {
-			if embed.Src != "" {
-				embedFilePath := filepath.Join(filepath.Dir(layout.Path), embed.Src)
-				log.Println("embedding", embedFilePath)
-				embedContent := readFile(embedFilePath)
+// expandEmbeds replaces the placeholders ParsePage or ParseLayout left for embeds under root with the embedded content.
+// embeds found in the embedded content are expanded in turn, resolved relative to the same file
+func (c *Compiler) expandEmbeds(root *html.Node, embeds []Embed, ctx *PageContext, relativeTo string, depth int) error {
+	if len(embeds) == 0 {
+		return nil
+	}
+	if depth >= parseLimit {
+		return fmt.Errorf("failed to compile %s. partials are nested more than %d levels deep", ctx.SrcPath, parseLimit)
+	}
 
-				if embed.Replace != "" {
-					embedContent = c.handleEmbedReplacements(embedContent, embed.Replace)
-				}
+	byPlaceholder := make(map[string]Embed)
+	for _, embed := range embeds {
+		switch embed.Type {
+		case "ham/page", "ham/layout-js", "ham/layout-css":
+			continue // filled in once the page is merged into its layout
+		}
+		byPlaceholder[embed.placeholder] = embed
+	}
+	var placeholders []*html.Node
+	walkNodes(root, func(n *html.Node) {
+		if _, ok := byPlaceholder[n.Data]; ok && n.Type == html.TextNode {
+			placeholders = append(placeholders, n)
+		}
+	})
 
-				c.pageHTML = bytes.ReplaceAll(c.pageHTML, []byte(embedPlaceholder(embed.Src)), embedContent)
+	for _, n := range placeholders {
+		embed := byPlaceholder[n.Data]
+		embedContent, err := c.embedContent(embed, ctx, relativeTo)
+		if err != nil {
+			return err
+		}
+
+		// parse the embedded content where it is going to live, so it gets the same treatment as the page
+		container, err := parseInContext(n.Parent, embedContent)
+		if err != nil {
+			return err
+		}
+		partial := ParsePage(container)
+		if err := c.expandEmbeds(container, partial.Embeds, ctx, relativeTo, depth+1); err != nil {
+			return err
+		}
+		if c.traceLinks {
+			from := embed.Type
+			if embed.Type == "ham/partial" {
+				from = path.Join(path.Dir(relativeTo), embed.Src)
 			}
+			markLinkSource(container, from)
+		}
+		replaceNode(n, container)
+	}
+	return nil
+}
+
+// applyLayout merges the page in doc into its layout and returns the layout document.
+// the page body takes the place of the layout's ham/page embed
+func (c *Compiler) applyLayout(doc *html.Node, ctx *PageContext) (*html.Node, error) {
+	layoutFilePath := path.Join(path.Dir(ctx.SrcPath), ctx.Page.Layout.Src)
+	if _, err := fs.Stat(c.src, layoutFilePath); err != nil {
+		return nil, fmt.Errorf("failed to compile %s. Layout file %s not found", ctx.SrcPath, layoutFilePath)
+	}
+	log.Printf("Compiling Page: %s with %s\n", ctx.SrcPath, layoutFilePath)
+
+	lDoc, err := html.Parse(bytes.NewReader(c.readFile(layoutFilePath)))
+	if err != nil {
+		return nil, err
+	}
+	layout := ParseLayout(lDoc)
+	layout.Path = layoutFilePath
+	if err := c.expandEmbeds(lDoc, layout.Embeds, ctx, layout.Path, 0); err != nil {
+		return nil, err
+	}
+	if c.traceLinks {
+		markLinkSource(lDoc, layoutFilePath)
+	}
+
+	slot := findPlaceholder(lDoc, "{ham:page}")
+	if slot == nil {
+		return nil, fmt.Errorf("failed to compile %s. Layout file %s has no ham/page embed", ctx.SrcPath, layoutFilePath)
+	}
+
+	if pageBody := findElement(doc, "body"); pageBody != nil {
+		replaceNode(slot, pageBody)
+	}
+
+	return lDoc, nil
+}
+
+// pageResources returns the resource tags of the head and body slots of the page: the resources from its page config
+// and its companion .css and .ts files, sorted by their order.
+// only resources that exist are referenced. companion files are optional, a missing page config resource is reported
+// and fails the build when ham.json sets strict. with sri set the tags carry the integrity of their files,
+// with criticalCss set the stylesheets of the head are inlined or loaded without blocking rendering.
+// layoutCSS are the stylesheets taken from the layout to be bundled with the ones of the page
+func (c *Compiler) pageResources(ctx *PageContext, layoutCSS []Resource) ([]string, []string, error) {
+	pageFilePath := ctx.SrcPath
+	page := ctx.Page
+
+	var declared []Resource
+	for _, res := range page.Layout.CSS {
+		res.stylesheet = true // e.g. a font url without a .css extension
+		declared = append(declared, res)
+	}
+	declared = append(declared, page.Layout.Js...)
+	for _, res := range page.Layout.JsMod {
+		if res.Type == "" {
+			res.Type = "module"
 		}
+		declared = append(declared, res)
 	}
 
-	c.pageHTML = bytes.ReplaceAll(c.pageHTML, []byte("{ham:css}"), []byte(strings.Join(pageCSS, "\n")))
-	c.pageHTML = bytes.ReplaceAll(c.pageHTML, []byte("{ham:js}"), []byte(strings.Join(pageJs, "\n")))
+	// page config resources are relative to the page, companion files sit next to it
+	var pageResources []Resource
+	for _, res := range declared {
+		if !path.IsAbs(res.Src) && !strings.Contains(res.Src, "://") {
+			res.Src = path.Join(path.Dir(pageFilePath), res.Src) // re-adjust res path
+			if _, err := fs.Stat(c.src, res.Src); err != nil {
+				if c.config.Strict {
+					return nil, nil, fmt.Errorf("failed to compile %s. resource %s not found", pageFilePath, res.Src)
+				}
+				log.Printf("warning: %s: resource %s not found, skipping it\n", pageFilePath, res.Src)
+				continue
+			}
+		}
+		pageResources = append(pageResources, res)
+	}
+	pageBase := strings.TrimSuffix(pageFilePath, path.Ext(pageFilePath))
+	for _, res := range []string{pageBase + ".css", pageBase + ".ts"} {
+		if _, err := fs.Stat(c.src, res); err == nil {
+			pageResources = append(pageResources, Resource{Src: res})
+		}
+	}
+	sort.SliceStable(pageResources, func(i, j int) bool {
+		return pageResources[i].Order < pageResources[j].Order
+	})
 
-	// find and replace page embeds
-	for _, embed := range page.Embeds {
-		if embed.Src != "" {
-			embedFilePath := filepath.Join(filepath.Dir(pageFilePath), embed.Src)
-			log.Println("embedding", embedFilePath)
-			embedContent := readFile(embedFilePath)
+	log.Println("Resources", pageFilePath, pageResources)
+	dedupe := make(map[string]bool)
+	var head, body []string
+	asyncCSS := false
+	addTag := func(res Resource, critical bool) error {
+		if c.config.SRI && res.Integrity == "" {
+			integrity, err := c.resourceIntegrity(res)
+			if err != nil {
+				if c.config.Strict {
+					return fmt.Errorf("failed to compile %s. no integrity for %s: %v", pageFilePath, res.Src, err)
+				}
+				log.Printf("warning: %s: no integrity for %s: %v\n", pageFilePath, res.Src, err)
+			} else {
+				res.Integrity = integrity
+				if res.CrossOrigin == "" {
+					res.CrossOrigin = "anonymous" // integrity checks of cross-origin resources need cors
+				}
+			}
+		}
+		switch {
+		case critical:
+			head = append(head, res.asyncTag(c.url(assetURL(res.Src))))
+			asyncCSS = true
+		case res.placement() == placementHead:
+			head = append(head, res.tag(c.url(assetURL(res.Src))))
+		default:
+			body = append(body, res.tag(c.url(assetURL(res.Src))))
+		}
+		return nil
+	}
 
-			if embed.Replace != "" {
-				embedContent = c.handleEmbedReplacements(embedContent, embed.Replace)
+	// with bundleCss the local stylesheets of the head, starting with the ones the layout links, become one bundle
+	// which takes the place of the first of them
+	bundle := append([]Resource{}, layoutCSS...)
+	bundleAt := -1
+	if len(bundle) > 0 {
+		bundleAt = 0
+	}
+	for _, res := range pageResources {
+		if _, ok := dedupe[res.Src]; ok {
+			continue
+		}
+		dedupe[res.Src] = true
+		critical := c.config.CriticalCSS != nil && res.isCSS() && res.placement() == placementHead
+		if critical {
+			if style := c.inlineCSS(res, res.Src == pageBase+".css"); style != "" {
+				head = append(head, style)
+				continue
 			}
+		}
+		if c.config.BundleCSS && res.isCSS() && res.placement() == placementHead && res.Integrity == "" && !strings.Contains(res.Src, "://") {
+			if bundleAt < 0 {
+				bundleAt = len(head)
+			}
+			bundle = append(bundle, res)
+			continue
+		}
+		if err := addTag(res, critical); err != nil {
+			return nil, nil, err
+		}
+	}
 
-			c.pageHTML = bytes.ReplaceAll(c.pageHTML, []byte(embedPlaceholder(embed.Src)), embedContent)
+	if len(bundle) > 0 {
+		tags := head[bundleAt:]
+		head = head[:bundleAt:bundleAt]
+		bundled, err := c.cssBundle(bundle)
+		if err != nil {
+			log.Printf("warning: %s: linking its stylesheets one by one, they cannot be bundled: %v\n", pageFilePath, err)
+		} else {
+			bundle = []Resource{bundled}
 		}
+		for _, res := range bundle {
+			if err := addTag(res, c.config.CriticalCSS != nil); err != nil {
+				return nil, nil, err
+			}
+		}
+		head = append(head, tags...)
+	}
+	if asyncCSS {
+		head = append(head, asyncCSSLoader)
 	}
 
-	doc, err := html.Parse(bytes.NewBuffer(c.pageHTML))
-	if err != nil {
-		return nil, false, err
+	return head, body, nil
+}
+
+// assetURL returns the url a page resource is served from once built. resources in src keep their path below src,
+// src/blog/post.css is served from /assets/css/blog/post.css and src/blog/post.ts from /assets/js/blog/post.js.
+// other project files are served from their assets directory, absolute paths and urls are used as they are
+func assetURL(res string) string {
+	if path.IsAbs(res) || strings.Contains(res, "://") {
+		return res
 	}
+	kind := "js"
+	switch path.Ext(res) {
+	case ".css":
+		kind = "css"
+	case ".ts":
+		res = strings.TrimSuffix(res, ".ts") + ".js"
+	}
+
+	if rel, ok := srcRelative(res); ok {
+		return "/" + path.Join("assets", kind, rel)
+	}
+	if i := strings.Index("/"+res, "/assets/"); i >= 0 {
+		return "/" + res[i:]
+	}
+	return "/" + path.Join("assets", kind, path.Base(res))
+}
 
-	return doc, len(page.Embeds) > 0, nil
+// srcRelative returns the part of a project path below the src directory
+func srcRelative(name string) (string, bool) {
+	if name == srcDir {
+		return "", true
+	}
+	if strings.HasPrefix(name, srcDir+"/") {
+		return strings.TrimPrefix(name, srcDir+"/"), true
+	}
+	return "", false
 }
 
 func (c *Compiler) handleEmbedReplacements(content []byte, replacements string) []byte {
@@ -254,27 +702,34 @@ func (c *Compiler) handleEmbedReplacements(content []byte, replacements string)
 	return content
 }
 
-func (c *Compiler) Reset() {
-	c.pageHTML = nil
-	c.layoutHTML = nil
+// clearBuildCaches forgets the collections, resource digests and processed images of a build
+func (c *Compiler) clearBuildCaches() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.collections = nil
+	c.integrity = nil
+	c.images = nil
 }
 
-var readCache map[string][]byte
+// Reset is kept for compatibility. a render keeps its state in its PageContext, so there is nothing to reset
+func (c *Compiler) Reset() {}
 
-func readFile(filename string) []byte {
-	if readCache == nil {
-		readCache = make(map[string][]byte)
+func (c *Compiler) readFile(filename string) []byte {
+	c.mu.Lock()
+	file, ok := c.readCache[filename]
+	c.mu.Unlock()
+	if ok {
+		return file
 	}
 
-	if _, ok := readCache[filename]; !ok {
-		file, err := os.ReadFile(filename)
-		if err != nil {
-			return nil
-		}
-		readCache[filename] = file
+	file, err := fs.ReadFile(c.src, filename)
+	if err != nil {
+		return nil
 	}
-
-	return readCache[filename]
+	c.mu.Lock()
+	c.readCache[filename] = file
+	c.mu.Unlock()
+	return file
 }
 
 func createFile(filePath string, content []byte, override bool) error {
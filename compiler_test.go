@@ -0,0 +1,253 @@
+package ham
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/net/html"
+)
+
+// testFS returns a site made of files, keyed by name. ham.json is {} unless files has one
+func testFS(files map[string]string) fstest.MapFS {
+	src := fstest.MapFS{"ham.json": {Data: []byte(`{}`)}}
+	for name, data := range files {
+		src[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return src
+}
+
+// testSite returns a compiler of src writing to a new memory output
+func testSite(t *testing.T, src fs.FS, opts Options) (*Compiler, *MemoryOutput) {
+	t.Helper()
+	out := NewMemoryOutput()
+	c, err := NewFS(src, out, opts)
+	if err != nil {
+		t.Fatalf("new compiler failed: %v", err)
+	}
+	return c, out
+}
+
+// buildSite compiles src to a new memory output
+func buildSite(t *testing.T, src fs.FS, opts Options) (*Compiler, *MemoryOutput) {
+	t.Helper()
+	c, out := testSite(t, src, opts)
+	if err := c.Compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	return c, out
+}
+
+// renderDoc renders a page and parses the result
+func renderDoc(t *testing.T, c *Compiler, page string) *html.Node {
+	t.Helper()
+	b, err := c.RenderPage(page)
+	if err != nil {
+		t.Fatalf("render of %s failed: %v", page, err)
+	}
+	return parseDoc(t, b)
+}
+
+// readDoc parses a page of the output
+func readDoc(t *testing.T, out *MemoryOutput, name string) *html.Node {
+	t.Helper()
+	b, err := out.ReadFile(name)
+	if err != nil {
+		t.Fatalf("%s not written, got %v", name, out.Files())
+	}
+	return parseDoc(t, b)
+}
+
+func parseDoc(t *testing.T, b []byte) *html.Node {
+	t.Helper()
+	doc, err := html.Parse(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	return doc
+}
+
+// query returns the elements below n matching selector, a tag followed by [key] or [key=value] filters, e.g.
+// link[rel=stylesheet][media]
+func query(n *html.Node, selector string) []*html.Node {
+	tag := selector
+	var filters [][]string
+	if i := strings.Index(selector, "["); i >= 0 {
+		tag = selector[:i]
+		for _, f := range strings.Split(strings.Trim(selector[i:], "[]"), "][") {
+			filters = append(filters, strings.SplitN(f, "=", 2))
+		}
+	}
+	var found []*html.Node
+	walkNodes(n, func(n *html.Node) {
+		if n.Type != html.ElementNode || n.Data != tag {
+			return
+		}
+		for _, f := range filters {
+			if !hasAttr(n, f[0]) || len(f) == 2 && attr(n, f[0]) != f[1] {
+				return
+			}
+		}
+		found = append(found, n)
+	})
+	return found
+}
+
+// attrValues returns the key attribute of each node
+func attrValues(nodes []*html.Node, key string) []string {
+	values := []string{}
+	for _, n := range nodes {
+		values = append(values, attr(n, key))
+	}
+	return values
+}
+
+// texts returns the text content of each node
+func texts(nodes []*html.Node) []string {
+	values := []string{}
+	for _, n := range nodes {
+		values = append(values, textContent(n))
+	}
+	return values
+}
+
+// links returns the text and href of each link below n, e.g. "next /team/page/2/"
+func links(n *html.Node) []string {
+	values := []string{}
+	for _, a := range query(n, "a") {
+		values = append(values, textContent(a)+" "+attr(a, "href"))
+	}
+	return values
+}
+
+// outerHTML renders each node
+func outerHTML(nodes []*html.Node) []string {
+	values := []string{}
+	for _, n := range nodes {
+		var buf bytes.Buffer
+		html.Render(&buf, n)
+		values = append(values, buf.String())
+	}
+	return values
+}
+
+// equal reports whether got holds the values of want, in order
+func equal(got []string, want ...string) bool {
+	return reflect.DeepEqual(got, append([]string{}, want...))
+}
+
+func TestCompileFS(t *testing.T) {
+	_, out := buildSite(t, os.DirFS("./test-site"), Options{})
+
+	b, err := out.ReadFile("index.html")
+	if err != nil {
+		t.Fatalf("compile failed: index.html not written: %v", err)
+	}
+	doc := parseDoc(t, b)
+	if got := texts(query(doc, "title")); !equal(got, "HAM") {
+		t.Errorf("compile failed: expected the title HAM but got %q", got)
+	}
+	if got := texts(query(doc, "h1")); !equal(got, "Welcome to HAM") {
+		t.Errorf("compile failed: expected the heading Welcome to HAM but got %q", got)
+	}
+	body := textContent(query(doc, "body")[0])
+	for _, want := range []string{"Partial #4", "Replace: value"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("compile failed: expected the text %q in\n%s", want, body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	out.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != string(b) {
+		t.Errorf("serve failed: expected index.html but got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRenderString(t *testing.T) {
+	c, _ := testSite(t, os.DirFS("./test-site"), Options{})
+
+	b, err := c.RenderString(`<div data-ham-page-config='{"layout": "default.lhtml"}'><embed type="ham/partial" src="4.phtml"/></div>`, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	doc := parseDoc(t, b)
+	if got := texts(query(doc, "title")); !equal(got, "HAM") {
+		t.Errorf("render failed: expected the title HAM but got %q", got)
+	}
+	if body := textContent(query(doc, "body")[0]); !strings.Contains(body, "Partial #4") {
+		t.Errorf("render failed: expected the partial in\n%s", body)
+	}
+	if strings.Contains(string(b), "{embed:") || strings.Contains(string(b), "{ham:") {
+		t.Errorf("render failed: placeholders left in output %s", b)
+	}
+}
+
+func TestLayoutMerge(t *testing.T) {
+	c, _ := testSite(t, testFS(map[string]string{
+		"src/layout.lhtml": `<html lang="en"><head><meta charset="UTF-8"><link type="ham/layout-css"/></head><body class="site"><main><embed type="ham/page"/></main></body></html>`,
+		"src/index.html": `<link rel="canonical" href="/"><div data-ham-page-config='{"layout": "layout.lhtml"}'>
+<p class="ham-remove">gone</p><p>kept</p><embed type="ham/partial" src="row.phtml"/></div>`,
+		"src/row.phtml": `<table><tbody><tr><td>cell</td></tr></tbody></table><p class="ham-remove">gone too</p><p>after</p>`,
+		"src/index.css": ``,
+	}), Options{})
+
+	b, err := c.RenderPage("src/index.html")
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	want := `<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8"/><link rel="stylesheet" href="/assets/css/index.css"/><link rel="canonical" href="/"/></head>` +
+		`<body class="site"><main><div>
+<p>kept</p><table><tbody><tr><td>cell</td></tr></tbody></table><p>after</p></div></main></body></html>`
+	if string(b) != want {
+		t.Errorf("layout merge failed: expected\n%s\nbut got\n%s", want, b)
+	}
+}
+
+func TestRenderPageConcurrently(t *testing.T) {
+	var photo bytes.Buffer
+	png.Encode(&photo, image.NewGray(image.Rect(0, 0, 40, 20)))
+	c, _ := testSite(t, testFS(map[string]string{
+		"ham.json": `{"sri": true, "bundleCss": true, "images": {"widths": [10, 20]},
+			"collections": {"blog": {"glob": "src/blog/*.md"}}}`,
+		"src/layout.lhtml":   `<html><head><link type="ham/layout-css"/></head><body><embed type="ham/partial" src="nav.phtml"/><embed type="ham/page"/></body></html>`,
+		"src/nav.phtml":      `<nav><img src="/img/logo.png" alt="logo"></nav>`,
+		"src/index.html":     `<div data-ham-page-config='{"layout": "layout.lhtml", "css": ["site.css"]}'><ul><embed type="ham/collection" data-collection="blog" src="item.phtml"/></ul></div>`,
+		"src/about.html":     `<div data-ham-page-config='{"layout": "layout.lhtml", "css": ["site.css"]}'><img src="img/logo.png" alt="about"></div>`,
+		"src/item.phtml":     `<li>__title__</li>`,
+		"src/site.css":       `body { margin: 0; }`,
+		"src/img/logo.png":   photo.String(),
+		"src/blog/first.md":  "---\ntitle: First\ndate: 2024-01-05\n---\nfirst",
+		"src/blog/second.md": "---\ntitle: Second\ndate: 2024-02-01\n---\nsecond",
+	}), Options{})
+
+	pages := []string{"src/index.html", "src/about.html", "src/blog/first.md", "src/blog/second.md"}
+	errs := make(chan error, 8*len(pages))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for _, page := range pages {
+			wg.Add(1)
+			go func(page string) {
+				defer wg.Done()
+				if _, err := c.RenderPage(page); err != nil {
+					errs <- fmt.Errorf("%s: %v", page, err)
+				}
+			}(page)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent render failed: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package ham
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+)
+
+// Config holds the project settings read from ham.json
+type Config struct {
+	Plugins []string `json:"plugins,omitempty"` // names of registered plugins to run, in order
+	Minify  bool     `json:"minify,omitempty"`  // minify compiled pages
+	Pretty  bool     `json:"pretty,omitempty"`  // indent compiled pages consistently. ignored when minify is set
+	BaseURL string   `json:"baseUrl,omitempty"` // url the site is served from, e.g. https://example.com. enables sitemap.xml
+	Strict  bool     `json:"strict,omitempty"`  // fail the build when a page resource does not exist or has no integrity
+	SRI     bool     `json:"sri,omitempty"`     // add integrity and crossorigin attributes to the resource tags of pages
+
+	// BasePath is the directory the site is served under, e.g. /docs for https://example.com/docs/. generated links
+	// start with it and {ham:base} is replaced with it. RewriteLinks also prefixes root relative href and src attributes
+	BasePath     string `json:"basePath,omitempty"`
+	RewriteLinks bool   `json:"rewriteLinks,omitempty"`
+
+	// Permalink decides where pages are written: "pretty" writes src/about.html to about/index.html, a pattern such as
+	// "/blog/:year/:slug/" places pages freely. pages can set their own permalink in their page config or front matter
+	Permalink string `json:"permalink,omitempty"`
+
+	Sitemap SitemapConfig `json:"sitemap"`
+
+	// BundleCSS links one minified bundle per page in place of the local stylesheets of its layout and page config
+	BundleCSS bool `json:"bundleCss,omitempty"`
+
+	// CriticalCSS inlines the css of every page into its head and loads its other stylesheets without blocking rendering
+	CriticalCSS *CriticalCSSConfig `json:"criticalCss,omitempty"`
+
+	// Images resizes the local images of pages into responsive variants, e.g. "images": {"widths": [480, 960]}
+	Images *ImagesConfig `json:"images,omitempty"`
+
+	// CSP adds a Content-Security-Policy to every page, as a meta tag or in a _headers file the proxy serves
+	CSP *CSPConfig `json:"csp,omitempty"`
+
+	// Collections groups pages, e.g. blog posts, so they can be listed with a ham/collection embed and published as feeds
+	Collections map[string]CollectionConfig `json:"collections,omitempty"`
+
+	// Taxonomies generate a page for every tag or category found in the front matter of a collection
+	Taxonomies map[string]TaxonomyConfig `json:"taxonomies,omitempty"`
+
+	// Data holds arrays listing pages can paginate, written inline or as the path of a json file,
+	// e.g. "data": {"team": "src/data/team.json"}
+	Data map[string]json.RawMessage `json:"data,omitempty"`
+
+	// Environments holds settings that override the ones above when building for an environment, e.g.
+	// "environments": {"production": {"minify": true}}
+	Environments map[string]json.RawMessage `json:"environments,omitempty"`
+}
+
+// loadConfig reads ham.json and applies the settings of env on top when env is set
+func loadConfig(src fs.FS, env string) (Config, error) {
+	var config Config
+	b, err := fs.ReadFile(src, configFileName)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return config, fmt.Errorf("invalid %s: %v", configFileName, err)
+	}
+	if env == "" {
+		return config, nil
+	}
+
+	overrides, ok := config.Environments[env]
+	if !ok {
+		log.Printf("no settings for environment %s in %s, using defaults\n", env, configFileName)
+		return config, nil
+	}
+	if err := json.Unmarshal(overrides, &config); err != nil {
+		return config, fmt.Errorf("invalid %s environment %s: %v", configFileName, env, err)
+	}
+	return config, nil
+}
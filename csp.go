@@ -0,0 +1,219 @@
+package ham
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	cspMeta     = "meta"    // the policy of a page is written into its head
+	cspHeaders  = "headers" // the policies of all pages are written to _headers, served by the proxy and hosts like netlify
+	headersFile = "_headers"
+	cspHeader   = "Content-Security-Policy"
+	cspSelf     = "'self'"
+)
+
+// cspDirectives are the directives of a generated policy in the order they are written
+var cspDirectives = []string{"default-src", "script-src", "style-src", "img-src", "font-src", "media-src", "frame-src",
+	"connect-src", "object-src", "base-uri"}
+
+// CSPConfig enables a Content-Security-Policy for every page, allowing the inline scripts and styles of the page by
+// their hash and the origins of the resources it loads
+type CSPConfig struct {
+	Mode string `json:"mode,omitempty"` // meta (default) or headers
+
+	// Directives adds sources to the generated policy, e.g. {"connect-src": ["https://api.example.com"]}.
+	// directives that are only valid in headers, such as frame-ancestors, are ignored by browsers in meta mode
+	Directives map[string][]string `json:"directives,omitempty"`
+}
+
+// pageCSP returns the policy of a compiled page. pages that set their own policy in a meta tag get none
+func (c *Compiler) pageCSP(pageHTML []byte, ctx *PageContext) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(pageHTML))
+	if err != nil {
+		return "", err
+	}
+
+	sources := map[string][]string{
+		"default-src": {cspSelf},
+		"script-src":  {cspSelf},
+		"style-src":   {cspSelf},
+		"img-src":     {cspSelf},
+		"object-src":  {"'none'"},
+		"base-uri":    {cspSelf},
+	}
+	add := func(directive, source string) {
+		if source == "" {
+			return
+		}
+		for _, s := range sources[directive] {
+			if s == source {
+				return
+			}
+		}
+		sources[directive] = append(sources[directive], source)
+	}
+	hash := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+	}
+
+	ownPolicy := false
+	inlineAttrs := false
+	walkNodes(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		for _, a := range n.Attr {
+			if (strings.HasPrefix(a.Key, "on") && a.Key != "open") || a.Key == "style" {
+				inlineAttrs = true
+			}
+		}
+		switch n.Data {
+		case "meta":
+			ownPolicy = ownPolicy || strings.EqualFold(attr(n, "http-equiv"), cspHeader)
+		case "script":
+			if src := attr(n, "src"); src != "" {
+				add("script-src", cspSource(src))
+			} else if isJavaScript(attr(n, "type")) {
+				add("script-src", hash(textContent(n)))
+			}
+		case "style":
+			add("style-src", hash(textContent(n)))
+		case "link":
+			href := attr(n, "href")
+			for _, rel := range strings.Fields(strings.ToLower(attr(n, "rel"))) {
+				switch rel {
+				case "stylesheet":
+					add("style-src", cspSource(href))
+				case "modulepreload":
+					add("script-src", cspSource(href))
+				case "icon", "apple-touch-icon":
+					add("img-src", cspSource(href))
+				case "preload":
+					switch attr(n, "as") {
+					case "style":
+						add("style-src", cspSource(href))
+					case "script":
+						add("script-src", cspSource(href))
+					case "font":
+						add("font-src", cspSource(href))
+					case "image":
+						add("img-src", cspSource(href))
+					}
+				}
+			}
+		case "img", "source":
+			directive := "img-src"
+			if n.Data == "source" && n.Parent != nil && n.Parent.Data != "picture" {
+				directive = "media-src"
+			}
+			add(directive, cspSource(attr(n, "src")))
+			for _, candidate := range strings.Split(attr(n, "srcset"), ",") {
+				if fields := strings.Fields(candidate); len(fields) > 0 {
+					add(directive, cspSource(fields[0]))
+				}
+			}
+		case "video", "audio":
+			add("media-src", cspSource(attr(n, "src")))
+			add("img-src", cspSource(attr(n, "poster")))
+		case "iframe":
+			add("frame-src", cspSource(attr(n, "src")))
+		}
+	})
+	if ownPolicy {
+		return "", nil
+	}
+	if inlineAttrs {
+		log.Printf("warning: %s has inline event handlers or style attributes, its content security policy blocks them\n", ctx.SrcPath)
+	}
+
+	var extra []string
+	for directive, values := range c.config.CSP.Directives {
+		if _, ok := sources[directive]; !ok {
+			extra = append(extra, directive)
+		}
+		for _, v := range values {
+			add(directive, v)
+		}
+	}
+	sort.Strings(extra)
+
+	var policy []string
+	for _, directive := range append(append([]string{}, cspDirectives...), extra...) {
+		if values, ok := sources[directive]; ok {
+			policy = append(policy, directive+" "+strings.Join(values, " "))
+			delete(sources, directive)
+		}
+	}
+	return strings.Join(policy, "; "), nil
+}
+
+// cspSource returns the source a policy needs to allow url: nothing for urls of the site itself, data: for data urls
+// and the origin of any other url
+func cspSource(u string) string {
+	u = strings.TrimSpace(u)
+	if strings.HasPrefix(strings.ToLower(u), "data:") {
+		return "data:"
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	if parsed.Scheme == "" {
+		return parsed.Host // protocol relative
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// isJavaScript reports whether a script of type typ is executed, data blocks such as application/ld+json are not
+func isJavaScript(typ string) bool {
+	switch strings.ToLower(strings.TrimSpace(typ)) {
+	case "", "module", "text/javascript", "application/javascript":
+		return true
+	}
+	return false
+}
+
+// insertCSPMeta adds the policy to the head of a compiled page as a meta tag
+func insertCSPMeta(pageHTML []byte, policy string) []byte {
+	i := bytes.Index(bytes.ToLower(pageHTML), []byte("<head"))
+	if i < 0 {
+		return pageHTML
+	}
+	end := bytes.IndexByte(pageHTML[i:], '>')
+	if end < 0 {
+		return pageHTML
+	}
+	end += i + 1
+	meta := `<meta http-equiv="` + cspHeader + `" content="` + html.EscapeString(policy) + `">`
+	return append(append(append([]byte{}, pageHTML[:end]...), meta...), pageHTML[end:]...)
+}
+
+// writeHeaders writes the policies of the pages to _headers, one block per page url:
+//
+//	/about.html
+//	  Content-Security-Policy: default-src 'self'; ...
+//
+// the urls are relative to the output directory the file is in, without the base path, as ham proxy looks them up
+func (c *Compiler) writeHeaders() error {
+	if c.config.CSP == nil || c.config.CSP.Mode != cspHeaders {
+		return nil
+	}
+	var b bytes.Buffer
+	for _, ctx := range c.pages {
+		if ctx.csp == "" {
+			continue
+		}
+		b.WriteString("/" + pageURLPath(ctx.OutPath) + "\n")
+		b.WriteString("  " + cspHeader + ": " + ctx.csp + "\n")
+	}
+	return c.writePage(headersFile, b.Bytes())
+}
@@ -0,0 +1,54 @@
+package ham
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestContentSecurityPolicy(t *testing.T) {
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+	}
+	page := `<div data-ham-page-config='{"layout": "layout.lhtml"}'><img src="https://images.example.com/a.png">` +
+		`<script>console.log("hi")</script><script type="application/ld+json">{}</script></div>`
+	src := testFS(map[string]string{
+		"ham.json": `{"csp": {"directives": {"connect-src": ["https://api.example.com"]}}}`,
+		"src/layout.lhtml": `<html><head><style>p{color:red}</style>` +
+			`<script src="https://cdn.example.com/lib.js"></script></head><body><embed type="ham/page"/></body></html>`,
+		"src/index.html": page,
+		"src/own.html":   `<meta http-equiv="Content-Security-Policy" content="default-src *"><p>own</p>`,
+	})
+	policy := `default-src 'self'; script-src 'self' https://cdn.example.com ` + hash(`console.log("hi")`) +
+		`; style-src 'self' ` + hash(`p{color:red}`) + `; img-src 'self' https://images.example.com` +
+		`; connect-src https://api.example.com; object-src 'none'; base-uri 'self'`
+
+	_, out := buildSite(t, src, Options{})
+	meta := query(readDoc(t, out, "index.html"), "meta[http-equiv=Content-Security-Policy]")
+	if got := attrValues(meta, "content"); !equal(got, policy) || meta[0].Parent.Data != "head" || meta[0].PrevSibling != nil {
+		t.Errorf("content security policy failed: expected the policy first in the head\n%s\nbut got %q", policy, got)
+	}
+	if got := attrValues(query(readDoc(t, out, "own.html"), "meta[http-equiv=Content-Security-Policy]"), "content"); !equal(got, "default-src *") {
+		t.Errorf("content security policy failed: expected the policy of own.html to be kept but got %q", got)
+	}
+
+	src["ham.json"] = &fstest.MapFile{Data: []byte(`{"csp": {"mode": "headers", "directives": {"connect-src": ["https://api.example.com"]}}}`)}
+	_, out = buildSite(t, src, Options{})
+	if got := query(readDoc(t, out, "index.html"), "meta[http-equiv=Content-Security-Policy]"); len(got) != 0 {
+		t.Errorf("content security policy failed: expected no meta tag in headers mode but got %q", outerHTML(got))
+	}
+	b, err := out.ReadFile("_headers")
+	if want := "/\n  Content-Security-Policy: " + policy + "\n"; err != nil || string(b) != want {
+		t.Errorf("content security policy failed: expected _headers\n%s\nbut got\n%s", want, b)
+	}
+	// the pages are listed by their path in the output, which ham proxy serves from its root
+	src["ham.json"] = &fstest.MapFile{Data: []byte(`{"basePath": "/docs", "csp": {"mode": "headers"}}`)}
+	_, out = buildSite(t, src, Options{})
+	b, _ = out.ReadFile("_headers")
+	if !strings.HasPrefix(string(b), "/\n") || strings.Contains(string(b), "/docs") {
+		t.Errorf("content security policy failed: expected _headers without the base path but got\n%s", b)
+	}
+}
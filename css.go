@@ -0,0 +1,187 @@
+package ham
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// asyncCSSLoader turns the stylesheet preloads of a page into stylesheets. stylesheets a script inserts do not block
+// rendering, unlike the ones in the html, and an inline script is allowed by the content security policy of the page
+const asyncCSSLoader = `<script>document.querySelectorAll("link[data-ham-async]").forEach(function(l){` +
+	`var s=l.cloneNode();s.rel="stylesheet";s.removeAttribute("as");s.removeAttribute("data-ham-async");l.after(s)})</script>`
+
+// CriticalCSSConfig inlines the css of a page into its head and loads its other stylesheets without blocking rendering
+type CriticalCSSConfig struct {
+	// MaxSize also inlines the other local stylesheets of a page up to this many bytes. 0 only inlines the companion
+	// .css file of the page
+	MaxSize int `json:"maxSize,omitempty"`
+}
+
+var (
+	cssURL        = regexp.MustCompile(`url\(\s*(['"]?)([^'")]*)(['"]?)\s*\)`)
+	cssImport     = regexp.MustCompile(`@import\s+(['"])([^'"]+)(['"])`)
+	cssImportRule = regexp.MustCompile(`@import\s+(?:url\(\s*)?['"]?([^'")\s;]+)['"]?\s*\)?\s*([^;]*);`)
+)
+
+// inlineCSS returns res as a <style> element when it is inlined: always for the page's own css, for other local
+// stylesheets when they are at most maxSize bytes. it returns "" for stylesheets that are linked
+func (c *Compiler) inlineCSS(res Resource, own bool) string {
+	if strings.Contains(res.Src, "://") {
+		return ""
+	}
+	maxSize := c.config.CriticalCSS.MaxSize
+	if !own && maxSize <= 0 {
+		return ""
+	}
+	b, err := c.resourceContent(res)
+	if err != nil {
+		log.Printf("warning: cannot inline %s: %v\n", res.Src, err)
+		return ""
+	}
+	if !own && len(b) > maxSize {
+		return ""
+	}
+
+	css := rebaseCSSURLs(string(b), path.Dir(c.url(assetURL(res.Src))))
+	css = strings.ReplaceAll(css, "</style", `<\/style`)
+	if res.Media != "" {
+		return `<style media="` + html.EscapeString(res.Media) + `">` + css + "</style>"
+	}
+	return "<style>" + css + "</style>"
+}
+
+// rebaseCSSURLs makes the relative urls of a stylesheet served from the directory dir, e.g. url(img/bg.png), relative
+// to the root, so the css keeps working once it is moved into a page or a bundle
+func rebaseCSSURLs(css, dir string) string {
+	rebase := func(u string) string {
+		if u == "" || strings.HasPrefix(u, "/") || strings.HasPrefix(u, "#") || strings.Contains(u, ":") {
+			return u
+		}
+		return path.Join(dir, u)
+	}
+	css = cssURL.ReplaceAllStringFunc(css, func(m string) string {
+		parts := cssURL.FindStringSubmatch(m)
+		return "url(" + parts[1] + rebase(strings.TrimSpace(parts[2])) + parts[3] + ")"
+	})
+	return cssImport.ReplaceAllStringFunc(css, func(m string) string {
+		parts := cssImport.FindStringSubmatch(m)
+		return "@import " + parts[1] + rebase(parts[2]) + parts[3]
+	})
+}
+
+// cssBundle concatenates the stylesheets into one minified bundle named after its content, so pages linking the same
+// stylesheets share a bundle. local @imports are inlined and urls rebased, remote @imports are moved to the top.
+// bundles are written by Compile, pages rendered on their own only link them
+func (c *Compiler) cssBundle(sheets []Resource) (Resource, error) {
+	var imports []string
+	var css strings.Builder
+	seen := make(map[string]bool)
+	for _, sheet := range sheets {
+		content, err := c.bundleSheet(sheet.Src, seen, &imports)
+		if err != nil {
+			return Resource{}, err
+		}
+		if sheet.Media != "" && sheet.Media != "all" {
+			content = "@media " + sheet.Media + "{" + content + "}"
+		}
+		css.WriteString(content + "\n")
+	}
+
+	b := []byte(minifyCSS(strings.Join(imports, "") + css.String()))
+	sum := sha256.Sum256(b)
+	name := path.Join("assets", "css", "bundle-"+hex.EncodeToString(sum[:])[:12]+".css")
+	if c.built != nil && !c.built[name] {
+		if err := c.writePage(name, b); err != nil {
+			return Resource{}, err
+		}
+	}
+
+	bundle := Resource{Src: "/" + name, stylesheet: true}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.integrity == nil {
+		c.integrity = make(map[string]string)
+	}
+	c.integrity[bundle.Src] = integrity(b) // the bundle is not in the output during a dry run
+	return bundle, nil
+}
+
+// bundleSheet returns the stylesheet src with its local @imports inlined and its urls made root relative
+func (c *Compiler) bundleSheet(src string, seen map[string]bool, imports *[]string) (string, error) {
+	if seen[src] {
+		return "", nil // imported before
+	}
+	seen[src] = true
+	b, err := c.resourceContent(Resource{Src: src})
+	if err != nil {
+		return "", err
+	}
+
+	var importErr error
+	css := cssImportRule.ReplaceAllStringFunc(string(b), func(rule string) string {
+		parts := cssImportRule.FindStringSubmatch(rule)
+		target, media := parts[1], strings.TrimSpace(parts[2])
+		if strings.Contains(target, ":") || strings.HasPrefix(target, "//") {
+			*imports = append(*imports, rule)
+			return ""
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(src), target)
+		}
+		imported, err := c.bundleSheet(target, seen, imports)
+		if err != nil {
+			importErr = fmt.Errorf("%s imports %s: %v", src, parts[1], err)
+			return rule
+		}
+		if media != "" {
+			return "@media " + media + "{" + imported + "}"
+		}
+		return imported
+	})
+	if importErr != nil {
+		return "", importErr
+	}
+	return rebaseCSSURLs(css, path.Dir(c.url(assetURL(src)))), nil
+}
+
+// layoutStylesheets takes the links to local stylesheets out of the head of the layout in doc, up to its ham/layout-css
+// slot, so they can be bundled with the stylesheets of the page. links to files that are not in the output are kept
+func (c *Compiler) layoutStylesheets(doc *nethtml.Node) []Resource {
+	head := findElement(doc, "head")
+	if head == nil {
+		return nil
+	}
+	if slot := findPlaceholder(head, "{ham:css}"); slot == nil || slot.Parent != head {
+		return nil
+	}
+
+	var sheets []Resource
+	for n := head.FirstChild; n != nil; {
+		next := n.NextSibling
+		if n.Type == nethtml.TextNode && n.Data == "{ham:css}" {
+			break
+		}
+		href := strings.TrimPrefix(attr(n, "href"), basePlaceholder)
+		if c.config.BasePath != "" {
+			href = strings.TrimPrefix(href, c.config.BasePath)
+		}
+		if n.Type == nethtml.ElementNode && n.Data == "link" && attr(n, "rel") == "stylesheet" && attr(n, "integrity") == "" &&
+			strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//") {
+			res := Resource{Src: href, Media: attr(n, "media"), stylesheet: true}
+			if _, err := c.resourceContent(res); err == nil {
+				sheets = append(sheets, res)
+				head.RemoveChild(n)
+			}
+		}
+		n = next
+	}
+	return sheets
+}
@@ -0,0 +1,90 @@
+package ham
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCriticalCSS(t *testing.T) {
+	c, _ := testSite(t, testFS(map[string]string{
+		"ham.json": `{"criticalCss": {"maxSize": 20}}`,
+		"src/blog/post.html": `<div data-ham-page-config='{"css": ["small.css", "large.css", "https://fonts.example.com/css?family=Sans"]}'>` +
+			`<embed type="ham/layout-css"/><p>page</p></div>`,
+		"src/blog/post.css":  `.hero{background:url("img/hero.png")}`,
+		"src/blog/small.css": `p{margin:0}`,
+		"src/blog/large.css": `body{font-family:sans-serif;margin:0 auto}`,
+	}), Options{})
+
+	b, err := c.RenderPage("src/blog/post.html")
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	doc := parseDoc(t, b)
+	if got := texts(query(doc, "style")); !equal(got, `p{margin:0}`, `.hero{background:url("/assets/css/blog/img/hero.png")}`) {
+		t.Errorf("critical css failed: expected the small stylesheets inlined with their urls rebased but got %q", got)
+	}
+	if got := attrValues(query(doc, "link[rel=preload][as=style][data-ham-async]"), "href"); !equal(got, "/assets/css/blog/large.css", "https://fonts.example.com/css?family=Sans") {
+		t.Errorf("critical css failed: expected the other stylesheets to be preloaded but got %q", got)
+	}
+	if got := texts(query(doc, "noscript")); !equal(got, `<link rel="stylesheet" href="/assets/css/blog/large.css">`, `<link rel="stylesheet" href="https://fonts.example.com/css?family=Sans">`) {
+		t.Errorf("critical css failed: expected the preloaded stylesheets to be linked without script but got %q", got)
+	}
+	if got := query(doc, "link[rel=stylesheet]"); len(got) != 0 {
+		t.Errorf("critical css failed: expected no blocking stylesheets but got %q", outerHTML(got))
+	}
+	if !strings.Contains(string(b), asyncCSSLoader) {
+		t.Errorf("critical css failed: expected the loader of the preloaded stylesheets in\n%s", b)
+	}
+}
+
+func TestCSSBundle(t *testing.T) {
+	src := testFS(map[string]string{
+		"ham.json": `{"bundleCss": true}`,
+		"src/layout.lhtml": `<html><head><link rel="stylesheet" href="/assets/app/css/site.css"><link type="ham/layout-css"/>` +
+			`<link rel="stylesheet" href="/assets/app/css/late.css"></head><body><embed type="ham/page"/></body></html>`,
+		"src/a.html":         `<div data-ham-page-config='{"layout": "layout.lhtml", "css": ["base.css", {"src": "print.css", "media": "print"}, "https://fonts.example.com/css"]}'><p>page</p></div>`,
+		"src/base.css":       "@import \"parts/type.css\";\n@import url(https://fonts.example.com/inter.css);\nbody { margin: 0; }\n",
+		"src/parts/type.css": `h1 { background: url(../img/h1.png); }`,
+		"src/print.css":      `nav { display: none; }`,
+	})
+	src["src/b.html"] = src["src/a.html"]
+	c, out := testSite(t, src, Options{})
+	out.WriteFile("assets/app/css/site.css", []byte(`html { color: #111; }`))
+	out.WriteFile("assets/app/css/late.css", []byte(`p { color: red; }`))
+	if err := c.Compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	want := `@import url(https://fonts.example.com/inter.css);html{color:#111}h1{background:url(/assets/css/img/h1.png)}` +
+		`body{margin:0}@media print{nav{display:none}}`
+	var bundles []string
+	for _, name := range out.Files() {
+		if strings.HasPrefix(name, "assets/css/bundle-") {
+			bundles = append(bundles, name)
+		}
+	}
+	if len(bundles) != 1 {
+		t.Fatalf("css bundle failed: expected one bundle shared by both pages but got %v", bundles)
+	}
+	b, _ := out.ReadFile(bundles[0])
+	if string(b) != want {
+		t.Errorf("css bundle failed: expected\n%s\nbut got\n%s", want, b)
+	}
+	for _, page := range []string{"a.html", "b.html"} {
+		got := attrValues(query(readDoc(t, out, page), "link[rel=stylesheet]"), "href")
+		if !equal(got, "/"+bundles[0], "https://fonts.example.com/css", "/assets/app/css/late.css") {
+			t.Errorf("css bundle of %s failed: expected the bundle in place of the local stylesheets but got %q", page, got)
+		}
+	}
+
+	// rendering a page after the build links its bundle without writing it
+	src["src/print.css"] = &fstest.MapFile{Data: []byte(`nav { display: block; }`)}
+	files := len(out.Files())
+	if _, err := c.RenderPage("src/a.html"); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if len(out.Files()) != files {
+		t.Errorf("css bundle failed: expected render after compile to write nothing but got %v", out.Files())
+	}
+}
@@ -0,0 +1,29 @@
+package ham
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []byte("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n")
+	b := []byte("one\ntwo\nthree\nfour\nfive\nsix\nSEVEN\neight\nnine\nten\neleven\n")
+
+	want := `--- a
++++ b
+@@ -4,7 +4,8 @@
+ four
+ five
+ six
+-seven
++SEVEN
+ eight
+ nine
+ ten
++eleven
+`
+	if got := unifiedDiff("a", "b", a, b); got != want {
+		t.Errorf("diff failed: expected\n%s\nbut got\n%s", want, got)
+	}
+
+	if got := unifiedDiff("a", "b", a, a); got != "" {
+		t.Errorf("diff failed: expected no diff for equal input but got\n%s", got)
+	}
+}
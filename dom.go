@@ -0,0 +1,159 @@
+package ham
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func attrOr(n *html.Node, key, defaultValue string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return defaultValue
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key && a.Namespace == "" {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func children(n *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		nodes = append(nodes, child)
+	}
+	return nodes
+}
+
+func walkNodes(n *html.Node, fn func(n *html.Node)) {
+	fn(n)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walkNodes(child, fn)
+	}
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findPlaceholder returns the text node ParsePage or ParseLayout left in place of an embed
+func findPlaceholder(n *html.Node, placeholder string) *html.Node {
+	if n.Type == html.TextNode && n.Data == placeholder {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findPlaceholder(child, placeholder); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// parseInContext parses content as if it was inside parent and returns a detached copy of parent holding the result
+func parseInContext(parent *html.Node, content []byte) (*html.Node, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	if parent != nil && parent.Type == html.ElementNode {
+		context = &html.Node{Type: html.ElementNode, Data: parent.Data, DataAtom: parent.DataAtom, Namespace: parent.Namespace}
+	}
+	nodes, err := html.ParseFragment(bytes.NewReader(content), context)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		context.AppendChild(n)
+	}
+	return context, nil
+}
+
+// replaceNode puts the children of container in place of n
+func replaceNode(n, container *html.Node) {
+	for _, child := range children(container) {
+		container.RemoveChild(child)
+		n.Parent.InsertBefore(child, n)
+	}
+	n.Parent.RemoveChild(n)
+}
+
+// replacePlaceholders replaces every placeholder text node under root with the html in content
+func replacePlaceholders(root *html.Node, placeholder string, content []byte) error {
+	var found []*html.Node
+	walkNodes(root, func(n *html.Node) {
+		if n.Type == html.TextNode && n.Data == placeholder {
+			found = append(found, n)
+		}
+	})
+	for _, n := range found {
+		container, err := parseInContext(n.Parent, content)
+		if err != nil {
+			return err
+		}
+		replaceNode(n, container)
+	}
+	return nil
+}
+
+// isHeadElement reports whether a page element belongs in the layout <head>
+func isHeadElement(tag string) bool {
+	switch tag {
+	case "title", "meta", "link", "base":
+		return true
+	}
+	return false
+}
+
+// ensureDoctype makes sure the document starts with <!DOCTYPE html> so it never renders in quirks mode
+func ensureDoctype(doc *html.Node) {
+	for child := doc.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.DoctypeNode {
+			return
+		}
+	}
+	doc.InsertBefore(&html.Node{Type: html.DoctypeNode, Data: "html"}, doc.FirstChild)
+}
@@ -0,0 +1,99 @@
+package ham
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sync"
+)
+
+// EmbedHandler renders an <embed> of a registered type. The returned html replaces the <embed> tag
+// and may itself contain embeds
+type EmbedHandler func(ctx *EmbedContext) ([]byte, error)
+
+// EmbedContext is handed to an EmbedHandler for every embed of its type
+type EmbedContext struct {
+	Embed Embed        // type, src and all attributes of the <embed> tag
+	Page  *PageContext // page being compiled
+	Path  string       // file the embed is resolved against, the page or its layout
+	Index int          // number of embeds of this type already rendered on the page
+	src   fs.FS
+}
+
+// ReadFile reads a project file, resolving name relative to the file the embed is resolved against
+func (ctx *EmbedContext) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(ctx.src, ctx.Resolve(name))
+}
+
+// Resolve returns the project path of name relative to the file the embed is resolved against
+func (ctx *EmbedContext) Resolve(name string) string {
+	return path.Join(path.Dir(ctx.Path), name)
+}
+
+var (
+	embedTypesMu sync.RWMutex
+	embedTypes   = make(map[string]EmbedHandler)
+)
+
+// RegisterEmbedType adds an embed type, e.g. ham/json-ld. It panics if the type is already registered
+// or is one of the built in types
+func RegisterEmbedType(typ string, h EmbedHandler) {
+	embedTypesMu.Lock()
+	defer embedTypesMu.Unlock()
+	switch typ {
+	case "ham/partial", "ham/page", "ham/layout-js", "ham/layout-css", "ham/collection":
+		panic("ham: RegisterEmbedType cannot replace built in embed type " + typ)
+	}
+	if h == nil {
+		panic("ham: RegisterEmbedType handler is nil")
+	}
+	if _, dup := embedTypes[typ]; dup {
+		panic("ham: RegisterEmbedType called twice for embed type " + typ)
+	}
+	embedTypes[typ] = h
+}
+
+// unregisterEmbedType removes an embed type added by RegisterEmbedType, so a test can register it again on its next run
+func unregisterEmbedType(typ string) {
+	embedTypesMu.Lock()
+	defer embedTypesMu.Unlock()
+	delete(embedTypes, typ)
+}
+
+func lookupEmbedType(typ string) (EmbedHandler, bool) {
+	embedTypesMu.RLock()
+	defer embedTypesMu.RUnlock()
+	h, ok := embedTypes[typ]
+	return h, ok
+}
+
+// embedContent returns the html an embed is replaced with. relativeTo is the file src attributes are resolved against
+func (c *Compiler) embedContent(embed Embed, ctx *PageContext, relativeTo string) ([]byte, error) {
+	if embed.Type == "ham/partial" {
+		embedFilePath := path.Join(path.Dir(relativeTo), embed.Src)
+		log.Println("embedding", embedFilePath)
+		embedContent := c.readFile(embedFilePath)
+
+		if embed.Replace != "" {
+			embedContent = c.handleEmbedReplacements(embedContent, embed.Replace)
+		}
+		return embedContent, nil
+	}
+
+	if embed.Type == "ham/collection" {
+		return c.collectionEmbed(embed, ctx, relativeTo)
+	}
+
+	h, ok := lookupEmbedType(embed.Type)
+	if !ok {
+		return nil, fmt.Errorf("failed to compile %s. unknown embed type %s", ctx.SrcPath, embed.Type)
+	}
+	embedCtx := &EmbedContext{Embed: embed, Page: ctx, Path: relativeTo, Index: ctx.embedCount[embed.Type], src: c.src}
+	ctx.embedCount[embed.Type]++
+	content, err := h(embedCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %s. %s embed: %v", ctx.SrcPath, embed.Type, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,40 @@
+package ham
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCustomEmbedType(t *testing.T) {
+	RegisterEmbedType("ham/test-shout", func(ctx *EmbedContext) ([]byte, error) {
+		b, err := ctx.ReadFile(ctx.Embed.Src)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf(`<p data-index="%d">%s</p>`, ctx.Index, strings.ToUpper(string(b)))), nil
+	})
+	t.Cleanup(func() { unregisterEmbedType("ham/test-shout") })
+
+	c, _ := testSite(t, testFS(map[string]string{
+		"src/words.txt":   `hello`,
+		"src/index.html":  `<embed type="ham/test-shout" src="words.txt"/><embed type="ham/test-shout" src="words.txt"/><embed type="video/mp4" src="a.mp4"/>`,
+		"src/broken.html": `<embed type="ham/missing" src="words.txt"/>`,
+	}), Options{})
+
+	doc := renderDoc(t, c, "src/index.html")
+	shouts := query(doc, "p[data-index]")
+	if got := attrValues(shouts, "data-index"); !equal(got, "0", "1") {
+		t.Errorf("custom embed failed: expected the indexes 0 and 1 but got %q", got)
+	}
+	if got := texts(shouts); !equal(got, "HELLO", "HELLO") {
+		t.Errorf("custom embed failed: expected HELLO twice but got %q", got)
+	}
+	if got := attrValues(query(doc, "embed[type=video/mp4]"), "src"); !equal(got, "a.mp4") {
+		t.Errorf("custom embed failed: expected other embeds to be kept but got %q", got)
+	}
+
+	if _, err := c.RenderPage("src/broken.html"); err == nil || !strings.Contains(err.Error(), "ham/missing") {
+		t.Errorf("expected unknown embed type to be reported but got %v", err)
+	}
+}
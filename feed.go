@@ -0,0 +1,226 @@
+package ham
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultFeedLimit = 20
+
+// FeedConfig describes the feeds of a collection. feeds are written to rss.xml, atom.xml and feed.json in Path
+type FeedConfig struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Path        string `json:"path,omitempty"`  // output directory of the feeds. defaults to the collection name
+	Limit       int    `json:"limit,omitempty"` // most items in a feed. defaults to 20
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Summary string      `xml:"summary,omitempty"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Authors     []jsonAuthor   `json:"authors,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// writeFeeds writes the RSS 2.0, Atom and JSON feeds of every collection with a feed. feeds need absolute urls,
+// so nothing is written when ham.json has no baseUrl
+func (c *Compiler) writeFeeds() error {
+	var names []string
+	for name, conf := range c.config.Collections {
+		if conf.Feed != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	if c.config.BaseURL == "" {
+		log.Println("skipping collection feeds, ham.json has no baseUrl")
+		return nil
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		items, err := c.collection(name)
+		if err != nil {
+			return err
+		}
+		if err := c.writeFeed(name, *c.config.Collections[name].Feed, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) writeFeed(name string, conf FeedConfig, items []*collectionItem) error {
+	baseURL := strings.TrimSuffix(c.config.BaseURL, "/")
+	dir := strings.Trim(conf.Path, "/")
+	if dir == "" {
+		dir = name
+	}
+	home := baseURL + c.url("/"+dir+"/")
+	limit := conf.Limit
+	if limit <= 0 {
+		limit = defaultFeedLimit
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	var updated time.Time
+	for _, item := range items {
+		if item.Date.After(updated) {
+			updated = item.Date
+		}
+	}
+	var author *atomAuthor
+	var authors []jsonAuthor
+	if conf.Author != "" {
+		author = &atomAuthor{Name: conf.Author}
+		authors = []jsonAuthor{{Name: conf.Author}}
+	}
+
+	rssURL := baseURL + c.url("/"+path.Join(dir, "rss.xml"))
+	rss := rssFeed{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: rssChannel{
+		Title: conf.Title, Link: home, Description: conf.Description,
+		Self: atomLink{Href: rssURL, Rel: "self", Type: "application/rss+xml"},
+	}}
+	atomURL := baseURL + c.url("/"+path.Join(dir, "atom.xml"))
+	atom := atomFeed{Xmlns: "http://www.w3.org/2005/Atom", Title: conf.Title, ID: home, Author: author, Links: []atomLink{
+		{Href: home}, {Href: atomURL, Rel: "self", Type: "application/atom+xml"},
+	}}
+	jsonURL := baseURL + c.url("/"+path.Join(dir, "feed.json"))
+	feed := jsonFeed{Version: "https://jsonfeed.org/version/1.1", Title: conf.Title, HomePageURL: home, FeedURL: jsonURL,
+		Description: conf.Description, Authors: authors, Items: []jsonFeedItem{}}
+	if !updated.IsZero() {
+		rss.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+		atom.Updated = updated.Format(time.RFC3339)
+	} else {
+		// atom requires an updated date, without dated items it is the time of the build
+		atom.Updated = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	for _, item := range items {
+		link := baseURL + item.URL
+		entry := atomEntry{Title: item.Title, ID: link, Link: atomLink{Href: link}, Updated: atom.Updated,
+			Summary: item.Summary, Content: atomContent{Type: "html", Value: item.Content}}
+		jsonItem := jsonFeedItem{ID: link, URL: link, Title: item.Title, ContentHTML: item.Content, Summary: item.Summary}
+		rssItem := rssItem{Title: item.Title, Link: link, GUID: rssGUID{IsPermaLink: "true", Value: link}, Description: item.Content}
+		if !item.Date.IsZero() {
+			rssItem.PubDate = item.Date.Format(time.RFC1123Z)
+			entry.Updated = item.Date.Format(time.RFC3339)
+			jsonItem.DatePublished = item.Date.Format(time.RFC3339)
+		}
+		if itemAuthor := stringValue(item.Data["author"]); itemAuthor != "" {
+			entry.Author = &atomAuthor{Name: itemAuthor}
+		}
+		if tags, ok := item.Data["tags"].([]interface{}); ok {
+			for _, tag := range tags {
+				jsonItem.Tags = append(jsonItem.Tags, stringValue(tag))
+			}
+		}
+		rss.Channel.Items = append(rss.Channel.Items, rssItem)
+		atom.Entries = append(atom.Entries, entry)
+		feed.Items = append(feed.Items, jsonItem)
+	}
+
+	if err := c.writeXML(path.Join(dir, "rss.xml"), rss); err != nil {
+		return err
+	}
+	if err := c.writeXML(path.Join(dir, "atom.xml"), atom); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return c.writePage(path.Join(dir, "feed.json"), append(b, '\n'))
+}
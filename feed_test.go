@@ -0,0 +1,63 @@
+package ham
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+func TestFeeds(t *testing.T) {
+	_, out := buildSite(t, testFS(map[string]string{
+		"ham.json": `{"baseUrl": "https://example.com", "collections": {"blog": {"glob": "src/blog/*.md",
+			"feed": {"title": "Blog", "author": "HAM"}}}}`,
+		"src/blog/first.md":  "---\ntitle: First post\ndate: 2024-01-05\ntags: [go]\n---\nHello *world*\n",
+		"src/blog/second.md": "---\ntitle: Second & last\ndate: 2024-02-01\ndescription: The second one\n---\nBye\n",
+	}), Options{})
+
+	var rss rssFeed
+	b, _ := out.ReadFile("blog/rss.xml")
+	if err := xml.Unmarshal(b, &rss); err != nil || rss.Version != "2.0" || len(rss.Channel.Items) != 2 {
+		t.Fatalf("rss feed failed: expected 2 items but got %v\n%s", err, b)
+	}
+	if item := rss.Channel.Items[0]; item.Link != "https://example.com/blog/second.html" || item.PubDate != "Thu, 01 Feb 2024 00:00:00 +0000" {
+		t.Errorf("rss feed failed: expected the second post first but got %+v", item)
+	}
+
+	var atom atomFeed
+	b, _ = out.ReadFile("blog/atom.xml")
+	if err := xml.Unmarshal(b, &atom); err != nil || len(atom.Entries) != 2 {
+		t.Fatalf("atom feed failed: expected 2 entries but got %v\n%s", err, b)
+	}
+	if atom.Updated != "2024-02-01T00:00:00Z" || atom.Author == nil || atom.Author.Name != "HAM" {
+		t.Errorf("atom feed failed: expected the date of the newest post and the author but got %q and %v", atom.Updated, atom.Author)
+	}
+	if content := atom.Entries[1].Content; content.Type != "html" || content.Value != "<p>Hello <em>world</em></p>" {
+		t.Errorf("atom feed failed: expected the html of the first post but got %+v", content)
+	}
+
+	var feed jsonFeed
+	b, _ = out.ReadFile("blog/feed.json")
+	if err := json.Unmarshal(b, &feed); err != nil || len(feed.Items) != 2 {
+		t.Fatalf("json feed failed: expected 2 items but got %v\n%s", err, b)
+	}
+	if feed.FeedURL != "https://example.com/blog/feed.json" || !equal(feed.Items[1].Tags, "go") {
+		t.Errorf("json feed failed: expected the feed url and the tags of the first post but got %q and %q", feed.FeedURL, feed.Items[1].Tags)
+	}
+
+	// without dated items the atom feed is updated at the build
+	before := time.Now().Add(-time.Second)
+	_, out = buildSite(t, testFS(map[string]string{
+		"ham.json":             `{"baseUrl": "https://example.com", "collections": {"notes": {"glob": "src/notes/*.md", "feed": {"title": "Notes"}}}}`,
+		"src/notes/undated.md": "---\ntitle: Undated\n---\nNo date\n",
+	}), Options{})
+	atom = atomFeed{}
+	b, _ = out.ReadFile("notes/atom.xml")
+	if err := xml.Unmarshal(b, &atom); err != nil || len(atom.Entries) != 1 {
+		t.Fatalf("atom feed failed: expected one entry but got %v\n%s", err, b)
+	}
+	updated, err := time.Parse(time.RFC3339, atom.Updated)
+	if err != nil || updated.Before(before) || atom.Entries[0].Updated != atom.Updated {
+		t.Errorf("atom feed failed: expected the feed and entry to be updated at the build but got %q and %q", atom.Updated, atom.Entries[0].Updated)
+	}
+}
@@ -0,0 +1,314 @@
+package ham
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const formatIndent = "  "
+
+var (
+	fullDocument = regexp.MustCompile(`(?i)<!doctype|<html[\s>]`)
+	firstTag     = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9-]*)`)
+)
+
+// fragmentContext is the element a partial starting with the key element must be parsed in, so the parser keeps it
+var fragmentContext = map[string]atom.Atom{
+	"tr": atom.Tbody, "td": atom.Tr, "th": atom.Tr, "thead": atom.Table, "tbody": atom.Table, "tfoot": atom.Table,
+	"caption": atom.Table, "colgroup": atom.Table, "col": atom.Colgroup, "option": atom.Select, "optgroup": atom.Select,
+}
+
+// formatHTML renders a compiled page with consistent, deterministic indentation
+func formatHTML(content []byte) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	return formatNodes([]*html.Node{doc}), nil
+}
+
+// FormatSource formats a page, partial or layout in place of name. Sources are formatted as fragments so no
+// <html>, <head> or <body> is added to them, unless the source is a whole document
+func FormatSource(name string, content []byte) ([]byte, error) {
+	frontMatter, content := splitFrontMatter(content)
+	nodes, err := parseSource(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to format %s: %v", name, err)
+	}
+	formatted := formatNodes(nodes)
+
+	// refuse to write anything that does not parse back into the same document
+	again, err := parseSource(formatted)
+	if err != nil || structure(nodes) != structure(again) {
+		return nil, fmt.Errorf("failed to format %s: formatting would change the document", name)
+	}
+	return append(append([]byte{}, frontMatter...), formatted...), nil
+}
+
+func parseSource(content []byte) ([]*html.Node, error) {
+	if fullDocument.Match(content) {
+		doc, err := html.Parse(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		return []*html.Node{doc}, nil
+	}
+
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	if m := firstTag.FindSubmatch(content); m != nil {
+		if a, ok := fragmentContext[strings.ToLower(string(m[1]))]; ok {
+			context = &html.Node{Type: html.ElementNode, Data: a.String(), DataAtom: a}
+		}
+	}
+	nodes, err := html.ParseFragment(bytes.NewReader(content), context)
+	if err != nil {
+		return nil, err
+	}
+	// the nodes are put under a copy of the context, so each one knows its siblings and the element it is in
+	parent := &html.Node{Type: html.ElementNode, Data: context.Data, DataAtom: context.DataAtom}
+	for _, n := range nodes {
+		parent.AppendChild(n)
+	}
+	return nodes, nil
+}
+
+// structure renders nodes with insignificant whitespace removed, so two documents that only differ in formatting compare equal.
+// whitespace in text is collapsed but kept, except next to an element that starts its own line or at the edges of one
+func structure(nodes []*html.Node) string {
+	f := &formatter{}
+	buf := &bytes.Buffer{}
+	for _, n := range nodes {
+		f.structure(buf, n)
+	}
+	return buf.String()
+}
+
+func (f *formatter) structure(buf *bytes.Buffer, n *html.Node) {
+	switch n.Type {
+	case html.DocumentNode:
+	case html.TextNode:
+		text := whitespace.ReplaceAllString(n.Data, " ")
+		if n.PrevSibling == nil && f.startsLine(n.Parent) || n.PrevSibling != nil && f.isBlock(n.PrevSibling) {
+			text = strings.TrimLeft(text, " ")
+		}
+		if n.NextSibling == nil && f.startsLine(n.Parent) || n.NextSibling != nil && f.isBlock(n.NextSibling) {
+			text = strings.TrimRight(text, " ")
+		}
+		buf.WriteString(escapeText(text))
+		return
+	case html.ElementNode:
+		if hasAttr(n, preserveAttr) || n.Data == "pre" || n.Data == "textarea" || n.Data == "script" || n.Data == "style" {
+			html.Render(buf, n)
+			return
+		}
+		buf.WriteString(startTag(n))
+		if voidElements[n.Data] {
+			return
+		}
+	default:
+		html.Render(buf, n)
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		f.structure(buf, child)
+	}
+	if n.Type == html.ElementNode {
+		buf.WriteString("</" + n.Data + ">")
+	}
+}
+
+// startsLine reports whether the content of parent starts on a line of its own, where whitespace at its edges is dropped
+func (f *formatter) startsLine(parent *html.Node) bool {
+	return parent == nil || parent.Type != html.ElementNode || f.isBlock(parent)
+}
+
+func formatNodes(nodes []*html.Node) []byte {
+	f := &formatter{buf: &bytes.Buffer{}}
+	f.blockChildren(nodes, 0)
+	return f.buf.Bytes()
+}
+
+type formatter struct {
+	buf *bytes.Buffer
+}
+
+// blockChildren writes nodes one per line. runs of text and inline elements are joined into a single line
+func (f *formatter) blockChildren(nodes []*html.Node, depth int) {
+	var run []*html.Node
+	flush := func() {
+		line := &bytes.Buffer{}
+		for _, n := range run {
+			f.inline(line, n)
+		}
+		if text := strings.TrimSpace(line.String()); text != "" {
+			f.line(depth, text)
+		}
+		run = nil
+	}
+
+	for _, n := range nodes {
+		if f.isBlock(n) {
+			flush()
+			f.block(n, depth)
+			continue
+		}
+		run = append(run, n)
+	}
+	flush()
+}
+
+func (f *formatter) block(n *html.Node, depth int) {
+	switch n.Type {
+	case html.DocumentNode:
+		f.blockChildren(children(n), depth)
+		return
+	case html.DoctypeNode:
+		buf := &bytes.Buffer{}
+		html.Render(buf, n)
+		f.line(depth, buf.String())
+		return
+	case html.CommentNode:
+		f.line(depth, "<!--"+n.Data+"-->")
+		return
+	}
+
+	if hasAttr(n, preserveAttr) || n.Data == "pre" || n.Data == "textarea" || n.Data == "script" || n.Data == "style" {
+		buf := &bytes.Buffer{}
+		html.Render(buf, n)
+		f.line(depth, buf.String())
+		return
+	}
+
+	if !hasBlockChild(f, n) {
+		line := &bytes.Buffer{}
+		f.inline(line, n)
+		f.line(depth, strings.TrimSpace(line.String()))
+		return
+	}
+
+	f.line(depth, startTag(n))
+	f.blockChildren(children(n), depth+1)
+	f.line(depth, "</"+n.Data+">")
+}
+
+// inline writes n without line breaks, collapsing whitespace in text
+func (f *formatter) inline(buf *bytes.Buffer, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if n.Parent != nil && (n.Parent.Data == "script" || n.Parent.Data == "style") {
+			buf.WriteString(n.Data)
+			return
+		}
+		buf.WriteString(escapeText(whitespace.ReplaceAllString(n.Data, " ")))
+		return
+	case html.CommentNode:
+		buf.WriteString("<!--" + n.Data + "-->")
+		return
+	case html.ElementNode:
+	default:
+		html.Render(buf, n)
+		return
+	}
+
+	if hasAttr(n, preserveAttr) || n.Data == "pre" || n.Data == "textarea" {
+		html.Render(buf, n)
+		return
+	}
+
+	buf.WriteString(startTag(n))
+	if voidElements[n.Data] || (n.Namespace != "" && n.FirstChild == nil) {
+		return
+	}
+	inner := &bytes.Buffer{}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		f.inline(inner, child)
+	}
+	text := inner.String()
+	if blockElements[n.Data] {
+		text = strings.TrimSpace(text) // whitespace at the edges of a block is never rendered
+	}
+	buf.WriteString(text)
+	buf.WriteString("</" + n.Data + ">")
+}
+
+func (f *formatter) line(depth int, s string) {
+	f.buf.WriteString(strings.Repeat(formatIndent, depth))
+	f.buf.WriteString(s)
+	f.buf.WriteByte('\n')
+}
+
+// isBlock reports whether n starts its own line
+func (f *formatter) isBlock(n *html.Node) bool {
+	switch n.Type {
+	case html.DocumentNode, html.DoctypeNode:
+		return true
+	case html.ElementNode:
+		if n.Data == "embed" && isHamEmbed(attr(n, "type")) {
+			return !f.inInlineContent(n) // partials and pages usually hold blocks of their own
+		}
+		return blockElements[n.Data] || hasBlockChild(f, n)
+	case html.CommentNode:
+		return n.Parent == nil || n.Parent.Type != html.ElementNode || blockElements[n.Parent.Data]
+	}
+	return false
+}
+
+// inInlineContent reports whether n is inside an inline element or next to text or inline elements, where a line break
+// around it would add visible whitespace
+func (f *formatter) inInlineContent(n *html.Node) bool {
+	if n.Parent == nil {
+		return false
+	}
+	if n.Parent.Type == html.ElementNode && !blockElements[n.Parent.Data] {
+		return true
+	}
+	for sibling := n.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		switch {
+		case sibling == n:
+		case sibling.Type == html.TextNode && strings.TrimSpace(sibling.Data) != "":
+			return true
+		case sibling.Type == html.ElementNode && !(sibling.Data == "embed" && isHamEmbed(attr(sibling, "type"))) && !f.isBlock(sibling):
+			return true
+		}
+	}
+	return false
+}
+
+func hasBlockChild(f *formatter, n *html.Node) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && f.isBlock(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// startTag renders the start tag of n. attribute values holding double quotes are written in single quotes
+func startTag(n *html.Node) string {
+	var sb strings.Builder
+	sb.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		sb.WriteByte(' ')
+		if a.Namespace != "" {
+			sb.WriteString(a.Namespace + ":")
+		}
+		sb.WriteString(a.Key)
+		val := strings.ReplaceAll(a.Val, "&", "&amp;")
+		if strings.Contains(val, `"`) && !strings.Contains(val, "'") {
+			sb.WriteString(`='` + val + `'`)
+		} else {
+			sb.WriteString(`="` + strings.ReplaceAll(val, `"`, "&quot;") + `"`)
+		}
+	}
+	if n.Namespace != "" && n.FirstChild == nil {
+		sb.WriteString("/>")
+	} else {
+		sb.WriteByte('>')
+	}
+	return sb.String()
+}
@@ -0,0 +1,90 @@
+package ham
+
+import (
+	"testing"
+)
+
+func TestFormatSource(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "page",
+			in: `<div class="page" data-ham-page-config='{"layout": "default.lhtml"}'>
+<h1>Title</h1>   <p>Some   <b>bold</b>
+text</p><embed type="ham/partial" src="a.phtml"/>
+    <pre>  keep
+  this</pre>
+</div>`,
+			want: `<div class="page" data-ham-page-config='{"layout": "default.lhtml"}'>
+  <h1>Title</h1>
+  <p>Some <b>bold</b> text</p>
+  <embed type="ham/partial" src="a.phtml">
+  <pre>  keep
+  this</pre>
+</div>
+`,
+		},
+		{
+			name: "table rows",
+			in:   "<tr><td>a</td>\n<td>b</td></tr>",
+			want: "<tr>\n  <td>a</td>\n  <td>b</td>\n</tr>\n",
+		},
+		{
+			name: "layout",
+			in:   `<!DOCTYPE html><html lang="en"><head><title>HAM</title><link type="ham/layout-css"/></head><body><embed type="ham/page"/></body></html>`,
+			want: `<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <title>HAM</title>
+    <link type="ham/layout-css">
+  </head>
+  <body>
+    <embed type="ham/page">
+  </body>
+</html>
+`,
+		},
+		{
+			name: "inline embed",
+			in:   "<p>Hello <embed type=\"ham/partial\" src=\"name.phtml\"/>!</p>\nWelcome <embed type=\"ham/svg\" src=\"wave.svg\"/>",
+			want: "<p>Hello <embed type=\"ham/partial\" src=\"name.phtml\">!</p>\nWelcome <embed type=\"ham/svg\" src=\"wave.svg\">\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := FormatSource(tt.name, []byte(tt.in))
+		if err != nil {
+			t.Errorf("%s: format failed: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: format failed: expected\n%s\nbut got\n%s", tt.name, tt.want, got)
+		}
+
+		again, err := FormatSource(tt.name, got)
+		if err != nil || string(again) != string(got) {
+			t.Errorf("%s: format is not stable, got\n%s", tt.name, again)
+		}
+	}
+}
+
+func TestFormatStructure(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"<p>Hello <b>world</b>!</p>", "<p>Hello<b>world</b>!</p>"},
+		{"<p>Hello <embed type=\"ham/partial\" src=\"a.phtml\">!</p>", "<p>Hello\n<embed type=\"ham/partial\" src=\"a.phtml\">\n!</p>"},
+	} {
+		a, _ := parseSource([]byte(pair[0]))
+		b, _ := parseSource([]byte(pair[1]))
+		if structure(a) == structure(b) {
+			t.Errorf("format structure failed: expected %q and %q to differ", pair[0], pair[1])
+		}
+	}
+	a, _ := parseSource([]byte("<div>\n  <p>a</p>\n  <p>b</p>\n</div>"))
+	b, _ := parseSource([]byte("<div><p>a</p><p>b</p></div>"))
+	if structure(a) != structure(b) {
+		t.Errorf("format structure failed: expected indentation between blocks to be ignored but got %q and %q", structure(a), structure(b))
+	}
+}
@@ -0,0 +1,191 @@
+package ham
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const frontMatterDelimiter = "---"
+
+// frontMatterStrings are the page config keys whose values are read as text, so title: 1984 stays "1984"
+var frontMatterStrings = map[string]bool{
+	"layout": true, "path": true, "title": true, "description": true, "og:image": true, "canonical": true,
+}
+
+// parseFrontMatter splits the front matter block at the top of a page off the page markup
+//
+//	---
+//	title: About us
+//	tags: [news, releases]
+//	sitemap:
+//	  priority: 0.8
+//	---
+//
+// values are strings, numbers, booleans, lists or nested maps. pages without front matter are returned as they are
+func parseFrontMatter(src []byte) (map[string]interface{}, []byte, error) {
+	block, body := splitFrontMatter(src)
+	if block == nil {
+		return nil, body, nil
+	}
+
+	lines := strings.Split(strings.Trim(strings.ReplaceAll(string(block), "\r\n", "\n"), "\ufeff \t\n"), "\n")
+	var fmLines []string
+	for _, line := range lines[1 : len(lines)-1] {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fmLines = append(fmLines, strings.TrimRight(line, " \t"))
+	}
+	data, rest, err := parseFrontMatterMap(fmLines, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rest) > 0 {
+		return nil, nil, fmt.Errorf("unexpected front matter line %q", strings.TrimSpace(rest[0]))
+	}
+	return data, body, nil
+}
+
+// splitFrontMatter returns the front matter block of src with its delimiters and the markup that follows it.
+// a --- line without a closing --- line is markup, e.g. a markdown horizontal rule, so src is returned as it is
+func splitFrontMatter(src []byte) ([]byte, []byte) {
+	start := len(src) - len(bytes.TrimLeft(src, "\ufeff \t\r\n"))
+	if !bytes.HasPrefix(src[start:], []byte(frontMatterDelimiter+"\n")) && !bytes.HasPrefix(src[start:], []byte(frontMatterDelimiter+"\r\n")) {
+		return nil, src
+	}
+
+	i := start + bytes.IndexByte(src[start:], '\n') + 1
+	for i < len(src) {
+		end := bytes.IndexByte(src[i:], '\n')
+		if end < 0 {
+			end = len(src) - i
+		} else {
+			end++
+		}
+		if string(bytes.TrimRight(src[i:i+end], " \t\r\n")) == frontMatterDelimiter {
+			return src[:i+end], src[i+end:]
+		}
+		i += end
+	}
+	return nil, src
+}
+
+// parseFrontMatterMap reads key: value lines indented by exactly indent spaces and returns the lines it did not read
+func parseFrontMatterMap(lines []string, indent int) (map[string]interface{}, []string, error) {
+	data := make(map[string]interface{})
+	for len(lines) > 0 {
+		line := lines[0]
+		lineIndent := len(line) - len(strings.TrimLeft(line, " "))
+		if lineIndent < indent {
+			break
+		}
+		if lineIndent > indent {
+			return nil, nil, fmt.Errorf("unexpected indentation in front matter line %q", strings.TrimSpace(line))
+		}
+
+		// the key ends at the first colon followed by a space, so keys such as og:image may hold colons themselves
+		i := strings.Index(line+" ", ": ")
+		if i < 0 {
+			return nil, nil, fmt.Errorf("front matter line %q is not a key: value pair", strings.TrimSpace(line))
+		}
+		key := strings.TrimSpace(line[:i])
+		val := strings.TrimSpace(line[i+1:])
+		lines = lines[1:]
+
+		if val != "" {
+			if indent == 0 && frontMatterStrings[key] {
+				data[key] = unquoteFrontMatter(val)
+			} else {
+				data[key] = frontMatterValue(val)
+			}
+			continue
+		}
+
+		// a block list or a nested map follows
+		if len(lines) == 0 {
+			data[key] = ""
+			continue
+		}
+		next := lines[0]
+		nextIndent := len(next) - len(strings.TrimLeft(next, " "))
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(next), "- "):
+			var list []interface{}
+			for len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[0]), "- ") {
+				list = append(list, frontMatterValue(strings.TrimSpace(strings.TrimSpace(lines[0])[2:])))
+				lines = lines[1:]
+			}
+			data[key] = list
+		case nextIndent > indent:
+			nested, rest, err := parseFrontMatterMap(lines, nextIndent)
+			if err != nil {
+				return nil, nil, err
+			}
+			data[key] = nested
+			lines = rest
+		default:
+			data[key] = ""
+		}
+	}
+	return data, lines, nil
+}
+
+func frontMatterValue(val string) interface{} {
+	if strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]") {
+		list := []interface{}{}
+		for _, item := range strings.Split(val[1:len(val)-1], ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, frontMatterValue(item))
+			}
+		}
+		return list
+	}
+	if unquoted := unquoteFrontMatter(val); unquoted != val {
+		return unquoted
+	}
+	switch val {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	// only plain decimal numbers, ParseFloat also reads inf, nan and hex floats which json can not hold
+	if strings.Trim(val, "0123456789+-.eE") == "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return val
+}
+
+// unquoteFrontMatter returns val without the single or double quotes around it
+func unquoteFrontMatter(val string) string {
+	if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+		return val[1 : len(val)-1]
+	}
+	return val
+}
+
+// applyFrontMatter sets the page config from the front matter. front matter wins over a data-ham-page-config of the same page
+func applyFrontMatter(page *Page, frontMatter map[string]interface{}) error {
+	if len(frontMatter) == 0 {
+		return nil
+	}
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+	for key, val := range frontMatter {
+		page.Data[key] = val
+	}
+	b, err := json.Marshal(frontMatter)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, page.Layout); err != nil {
+		return fmt.Errorf("invalid front matter: %v", err)
+	}
+	return nil
+}
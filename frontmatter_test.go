@@ -0,0 +1,40 @@
+package ham
+
+import "testing"
+
+func TestUnclosedFrontMatter(t *testing.T) {
+	c, _ := testSite(t, testFS(map[string]string{"src/rule.html": "---\n<p>rule</p>"}), Options{})
+
+	b, err := c.RenderPage("src/rule.html")
+	if err != nil {
+		t.Fatalf("unclosed front matter failed: expected the page to compile but got %v", err)
+	}
+	want := "<!DOCTYPE html><html><head></head><body>---\n<p>rule</p></body></html>"
+	if string(b) != want {
+		t.Errorf("unclosed front matter failed: expected\n%s\nbut got\n%s", want, b)
+	}
+}
+
+func TestFrontMatterScalars(t *testing.T) {
+	c, _ := testSite(t, testFS(map[string]string{
+		"src/book.html":  "---\ntitle: 1984\ndescription: 007\nrating: 4.5\n---\n<p>book</p>",
+		"src/other.html": "---\ntitle: Inf\nscore: NaN\n---\n<p>other</p>",
+	}), Options{})
+
+	b, err := c.RenderPage("src/book.html")
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	want := `<!DOCTYPE html><html><head><title>1984</title><meta name="description" content="007"/></head><body><p>book</p></body></html>`
+	if string(b) != want {
+		t.Errorf("front matter scalars failed: expected\n%s\nbut got\n%s", want, b)
+	}
+
+	if _, err := c.RenderPage("src/other.html"); err != nil {
+		t.Errorf("front matter scalars failed: expected Inf and NaN to be read as text but got %v", err)
+	}
+	fm, _, err := parseFrontMatter([]byte("---\nrating: 4.5\nscore: NaN\n---\n"))
+	if err != nil || fm["rating"] != 4.5 || fm["score"] != "NaN" {
+		t.Errorf("front matter scalars failed: expected a number and a string but got %v (%v)", fm, err)
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/fobilow/detach v0.0.0-20240511105825-cee5f1fa1808 // indirect
 	github.com/gin-contrib/gzip v1.0.1
 	github.com/gin-gonic/gin v1.10.0
+	github.com/yuin/goldmark v1.5.4
 	golang.org/x/crypto v0.26.0
 	golang.org/x/net v0.25.0
 )
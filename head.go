@@ -0,0 +1,149 @@
+package ham
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const headTemplateAttr = "data-ham-head"
+
+// pageHead detaches everything the page contributes to the <head>: the children of its own head, head elements at the top
+// of its body, the content of <template data-ham-head> elements and the tags generated from the page config and front matter
+func pageHead(doc *html.Node, page *Page) []*html.Node {
+	var nodes []*html.Node
+	if head := findElement(doc, "head"); head != nil {
+		for _, n := range children(head) {
+			head.RemoveChild(n)
+			if isHeadTemplate(n) {
+				for _, child := range children(n) {
+					n.RemoveChild(child)
+					nodes = append(nodes, child)
+				}
+				continue
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	if body := findElement(doc, "body"); body != nil {
+		for _, n := range children(body) {
+			if n.Type == html.ElementNode && isHeadElement(n.Data) {
+				body.RemoveChild(n)
+				nodes = append(nodes, n)
+			}
+		}
+	}
+
+	var templates []*html.Node
+	walkNodes(doc, func(n *html.Node) {
+		if isHeadTemplate(n) {
+			templates = append(templates, n)
+		}
+	})
+	for _, t := range templates {
+		for _, n := range children(t) {
+			t.RemoveChild(n)
+			nodes = append(nodes, n)
+		}
+		t.Parent.RemoveChild(t)
+	}
+
+	return append(nodes, configHead(page)...)
+}
+
+func isHeadTemplate(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.Data == "template" && hasAttr(n, headTemplateAttr)
+}
+
+// configHead returns the head tags for the title, description, og:image and canonical of the page config.
+// other og: and twitter: keys of the front matter become meta tags as they are
+func configHead(page *Page) []*html.Node {
+	var nodes []*html.Node
+	if page.Layout.Title != "" {
+		title := element("title")
+		title.AppendChild(&html.Node{Type: html.TextNode, Data: page.Layout.Title})
+		nodes = append(nodes, title)
+	}
+	if page.Layout.Description != "" {
+		nodes = append(nodes, element("meta", "name", "description", "content", page.Layout.Description))
+	}
+	if page.Layout.Image != "" {
+		nodes = append(nodes, element("meta", "property", "og:image", "content", page.Layout.Image))
+	}
+	if page.Layout.Canonical != "" {
+		nodes = append(nodes, element("link", "rel", "canonical", "href", page.Layout.Canonical))
+	}
+
+	var keys []string
+	for key := range page.Data {
+		if (strings.HasPrefix(key, "og:") || strings.HasPrefix(key, "twitter:")) && key != "og:image" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		val, ok := page.Data[key].(string)
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(key, "og:") {
+			nodes = append(nodes, element("meta", "property", key, "content", val))
+		} else {
+			nodes = append(nodes, element("meta", "name", key, "content", val))
+		}
+	}
+	return nodes
+}
+
+// mergeHead appends nodes to head. a node replaces the element it conflicts with, so the head keeps one <title>,
+// one <base>, one canonical link and one <meta> per name or property. the node added last wins
+func mergeHead(head *html.Node, nodes []*html.Node) {
+	for _, n := range nodes {
+		if key := headKey(n); key != "" {
+			for _, existing := range children(head) {
+				if headKey(existing) == key {
+					head.RemoveChild(existing)
+				}
+			}
+		}
+		head.AppendChild(n)
+	}
+}
+
+// headKey identifies head elements of which a document holds only one
+func headKey(n *html.Node) string {
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	switch n.Data {
+	case "title", "base":
+		return n.Data
+	case "meta":
+		switch {
+		case hasAttr(n, "charset"):
+			return "charset"
+		case hasAttr(n, "name"):
+			return "name:" + strings.ToLower(attr(n, "name"))
+		case hasAttr(n, "property"):
+			return "property:" + attr(n, "property")
+		case hasAttr(n, "http-equiv"):
+			return "http-equiv:" + strings.ToLower(attr(n, "http-equiv"))
+		}
+	case "link":
+		if strings.EqualFold(attr(n, "rel"), "canonical") {
+			return "canonical"
+		}
+	}
+	return ""
+}
+
+// element creates an element with attributes given as key, value pairs
+func element(tag string, attrs ...string) *html.Node {
+	n := &html.Node{Type: html.ElementNode, Data: tag, DataAtom: atom.Lookup([]byte(tag))}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.Attr = append(n.Attr, html.Attribute{Key: attrs[i], Val: attrs[i+1]})
+	}
+	return n
+}
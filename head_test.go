@@ -0,0 +1,27 @@
+package ham
+
+import "testing"
+
+func TestPageHead(t *testing.T) {
+	c, _ := testSite(t, testFS(map[string]string{
+		"src/layout.lhtml": `<html><head><title>Site</title><meta name="description" content="site"><link rel="canonical" href="/"></head>` +
+			`<body><embed type="ham/page"/></body></html>`,
+		"src/about.html": `---
+layout: layout.lhtml
+title: About us
+canonical: /about/
+og:image: /img/about.png
+---
+<template data-ham-head><meta name="description" content="about"><link rel="canonical" href="/ignored/"></template><p>about</p>`,
+	}), Options{})
+
+	b, err := c.RenderPage("src/about.html")
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	want := `<!DOCTYPE html><html><head><meta name="description" content="about"/><title>About us</title>` +
+		`<meta property="og:image" content="/img/about.png"/><link rel="canonical" href="/about/"/></head><body><p>about</p></body></html>`
+	if string(b) != want {
+		t.Errorf("page head failed: expected\n%s\nbut got\n%s", want, b)
+	}
+}
@@ -0,0 +1,24 @@
+package helper
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Slugify lower cases s and replaces every run of characters other than letters and digits with a single dash
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
@@ -0,0 +1,385 @@
+package ham
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // read by image.DecodeConfig, gif images are never re-encoded
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const imageCacheDir = ".ham-cache/img"
+
+var (
+	defaultImageWidths  = []int{480, 960, 1440}
+	defaultImageSizes   = "100vw"
+	defaultImageQuality = 80
+)
+
+// ImagesConfig resizes the local images of compiled pages. every <img> whose src is a jpeg or png file in the project
+// gets a srcset of narrower variants, sizes, and its width and height
+type ImagesConfig struct {
+	Widths  []int    `json:"widths,omitempty"`  // widths of the variants in pixels. defaults to 480, 960 and 1440
+	Sizes   string   `json:"sizes,omitempty"`   // sizes of images without one. defaults to 100vw
+	Quality int      `json:"quality,omitempty"` // quality of jpeg and lossy formats, 1 to 100. defaults to 80
+	Formats []string `json:"formats,omitempty"` // formats offered with <picture>, e.g. webp. see RegisterImageFormat
+}
+
+// ImageEncoder writes img in an image format. quality is the one of ham.json, lossless formats ignore it
+type ImageEncoder func(w io.Writer, img image.Image, quality int) error
+
+// ImageFormat is an image format pages can offer besides the one of their images
+type ImageFormat struct {
+	Ext      string // file extension, e.g. .webp
+	MimeType string // type of the <source>, e.g. image/webp
+	Encode   ImageEncoder
+}
+
+var (
+	imageFormatsMu sync.RWMutex
+	imageFormats   = make(map[string]ImageFormat)
+)
+
+// RegisterImageFormat adds a format the images setting of ham.json can list, e.g. webp with an encoder from
+// a package such as github.com/chai2010/webp. HAM only depends on the standard library for images, which has no
+// webp or avif encoder. It panics if the format is already registered
+func RegisterImageFormat(name string, f ImageFormat) {
+	imageFormatsMu.Lock()
+	defer imageFormatsMu.Unlock()
+	if f.Encode == nil || f.Ext == "" || f.MimeType == "" {
+		panic("ham: RegisterImageFormat format " + name + " needs an extension, a mime type and an encoder")
+	}
+	if _, dup := imageFormats[name]; dup {
+		panic("ham: RegisterImageFormat called twice for format " + name)
+	}
+	imageFormats[name] = f
+}
+
+// unregisterImageFormat removes a format added by RegisterImageFormat, so a test can register it again on its next run
+func unregisterImageFormat(name string) {
+	imageFormatsMu.Lock()
+	defer imageFormatsMu.Unlock()
+	delete(imageFormats, name)
+}
+
+func lookupImageFormat(name string) (ImageFormat, bool) {
+	imageFormatsMu.RLock()
+	defer imageFormatsMu.RUnlock()
+	f, ok := imageFormats[name]
+	return f, ok
+}
+
+// processedImage holds the urls an image of the project is served from once built
+type processedImage struct {
+	width, height int
+	src           string            // url of the original image
+	srcset        string            // the original and its narrower variants
+	sources       map[string]string // srcset by mime type of the other formats
+	types         []string          // mime types of sources, in the order of ham.json
+}
+
+// processImages gives the local images of a page a srcset, sizes and dimensions, writing their variants to
+// assets/img. images are written by Compile, pages rendered on their own only link them
+func (c *Compiler) processImages(doc *html.Node, ctx *PageContext) error {
+	var imgs []*html.Node
+	walkNodes(doc, func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "img" && !hasAttr(n, "data-ham-preserve") {
+			imgs = append(imgs, n)
+		}
+	})
+
+	for _, img := range imgs {
+		file, ok := c.imageFile(attr(img, "src"), ctx.SrcPath)
+		if !ok {
+			continue
+		}
+		processed, err := c.processImage(file)
+		if err != nil {
+			return fmt.Errorf("failed to compile %s. image %s: %v", ctx.SrcPath, file, err)
+		}
+
+		if !hasAttr(img, "width") && !hasAttr(img, "height") {
+			setAttr(img, "width", strconv.Itoa(processed.width))
+			setAttr(img, "height", strconv.Itoa(processed.height))
+		}
+		if hasAttr(img, "srcset") {
+			continue // written by hand
+		}
+		setAttr(img, "src", processed.src)
+		setAttr(img, "srcset", processed.srcset)
+		sizes := attrOr(img, "sizes", c.config.Images.Sizes)
+		if sizes == "" {
+			sizes = defaultImageSizes
+		}
+		setAttr(img, "sizes", sizes)
+
+		if len(processed.types) == 0 || (img.Parent != nil && img.Parent.Data == "picture") {
+			continue
+		}
+		picture := &html.Node{Type: html.ElementNode, Data: "picture", DataAtom: atom.Picture}
+		img.Parent.InsertBefore(picture, img)
+		img.Parent.RemoveChild(img)
+		for _, typ := range processed.types {
+			picture.AppendChild(&html.Node{Type: html.ElementNode, Data: "source", DataAtom: atom.Source, Attr: []html.Attribute{
+				{Key: "type", Val: typ}, {Key: "srcset", Val: processed.sources[typ]}, {Key: "sizes", Val: sizes},
+			}})
+		}
+		picture.AppendChild(img)
+	}
+	return nil
+}
+
+// imageFile returns the project file of the src of an <img>. relative sources are resolved against the page,
+// root relative ones against src, e.g. /img/logo.png is src/img/logo.png
+func (c *Compiler) imageFile(src, pagePath string) (string, bool) {
+	if src == "" || strings.Contains(src, ":") || strings.HasPrefix(src, "//") || strings.ContainsAny(src, "?#{") {
+		return "", false
+	}
+	switch strings.ToLower(path.Ext(src)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+	default:
+		return "", false
+	}
+	file := path.Join(path.Dir(pagePath), src)
+	if path.IsAbs(src) {
+		file = path.Join(srcDir, strings.TrimPrefix(src, c.config.BasePath))
+	}
+	if _, err := fs.Stat(c.src, file); err != nil {
+		return "", false
+	}
+	return file, true
+}
+
+// processImage writes the original image and its variants once per build
+func (c *Compiler) processImage(file string) (*processedImage, error) {
+	c.mu.Lock()
+	processed, ok := c.images[file]
+	c.mu.Unlock()
+	if ok {
+		return processed, nil
+	}
+	b, err := fs.ReadFile(c.src, file)
+	if err != nil {
+		return nil, err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	rel, ok := srcRelative(file)
+	if !ok {
+		rel = file
+	}
+	ext := path.Ext(rel)
+	name := path.Join("assets", "img", rel)
+	base := strings.TrimSuffix(name, ext)
+	processed = &processedImage{width: config.Width, height: config.Height, src: c.url("/" + name), sources: make(map[string]string)}
+	if err := c.writeAsset(name, b); err != nil {
+		return nil, err
+	}
+
+	var widths []int
+	if format != "gif" { // animations are kept as they are
+		for _, w := range c.config.Images.Widths {
+			if w > 0 && w < config.Width {
+				widths = append(widths, w)
+			}
+		}
+		if c.config.Images.Widths == nil {
+			for _, w := range defaultImageWidths {
+				if w < config.Width {
+					widths = append(widths, w)
+				}
+			}
+		}
+		sort.Ints(widths)
+	}
+
+	quality := c.config.Images.Quality
+	if quality <= 0 || quality > 100 {
+		quality = defaultImageQuality
+	}
+	sum := sha256.Sum256(b)
+	key := hex.EncodeToString(sum[:8])
+	var decoded image.Image
+	variant := func(w int, ext string, encode ImageEncoder) (string, error) {
+		variantName := fmt.Sprintf("%s-%d%s", base, w, ext)
+		data, err := c.cachedImage(fmt.Sprintf("%s-%d-q%d%s", key, w, quality, ext), func() ([]byte, error) {
+			if decoded == nil {
+				img, _, err := image.Decode(bytes.NewReader(b))
+				if err != nil {
+					return nil, err
+				}
+				decoded = img
+			}
+			var buf bytes.Buffer
+			if err := encode(&buf, resizeImage(decoded, w), quality); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		})
+		if err != nil {
+			return "", err
+		}
+		if err := c.writeAsset(variantName, data); err != nil {
+			return "", err
+		}
+		return c.url("/"+variantName) + " " + strconv.Itoa(w) + "w", nil
+	}
+
+	var srcset []string
+	for _, w := range widths {
+		candidate, err := variant(w, ext, encodeAs(format))
+		if err != nil {
+			return nil, err
+		}
+		srcset = append(srcset, candidate)
+	}
+	processed.srcset = strings.Join(append(srcset, processed.src+" "+strconv.Itoa(config.Width)+"w"), ", ")
+
+	for _, formatName := range c.config.Images.Formats {
+		f, _ := lookupImageFormat(formatName) // NewFS made sure it is registered
+		if format == "gif" {
+			continue
+		}
+		var sources []string
+		for _, w := range append(widths, config.Width) {
+			candidate, err := variant(w, f.Ext, f.Encode)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, candidate)
+		}
+		processed.types = append(processed.types, f.MimeType)
+		processed.sources[f.MimeType] = strings.Join(sources, ", ")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.images == nil {
+		c.images = make(map[string]*processedImage)
+	}
+	c.images[file] = processed
+	return processed, nil
+}
+
+// cachedImage returns the image of key from the cache, creating it when it is not there. the cache is kept in memory
+// and, for projects on disk, in .ham-cache so unchanged images are not resized again by the next build
+func (c *Compiler) cachedImage(key string, create func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	data, ok := c.imageCache[key]
+	c.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	cacheFile := ""
+	if c.workingDir != "" {
+		cacheFile = filepath.Join(c.workingDir, filepath.FromSlash(imageCacheDir), key)
+		if data, err := os.ReadFile(cacheFile); err == nil {
+			c.mu.Lock()
+			c.imageCache[key] = data
+			c.mu.Unlock()
+			return data, nil
+		}
+	}
+
+	data, err := create()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.imageCache[key] = data
+	c.mu.Unlock()
+	if cacheFile != "" && !c.opts.DryRun {
+		if err := os.MkdirAll(filepath.Dir(cacheFile), os.ModePerm); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(cacheFile, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// writeAsset writes a file shared by pages once per build
+func (c *Compiler) writeAsset(name string, data []byte) error {
+	if c.built == nil || c.built[name] {
+		return nil
+	}
+	return c.writePage(name, data)
+}
+
+// encodeAs returns the encoder of an image format read by image.Decode
+func encodeAs(format string) ImageEncoder {
+	if format == "jpeg" {
+		return func(w io.Writer, img image.Image, quality int) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+		}
+	}
+	return func(w io.Writer, img image.Image, quality int) error {
+		return (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(w, img)
+	}
+}
+
+// resizeImage scales img down to width pixels, keeping its aspect ratio. every pixel of the result is the average
+// of the pixels of img it covers
+func resizeImage(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	height := (srcH*width + srcW/2) / srcW
+	if height < 1 {
+		height = 1
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcH/height, (y+1)*srcH/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcW/width, (x+1)*srcW/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					alpha := uint64(src.Pix[i+3])
+					r += uint64(src.Pix[i]) * alpha
+					g += uint64(src.Pix[i+1]) * alpha
+					b += uint64(src.Pix[i+2]) * alpha
+					a += alpha
+					n++
+					i += 4
+				}
+			}
+			o := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[o], dst.Pix[o+1], dst.Pix[o+2] = uint8(r/a), uint8(g/a), uint8(b/a)
+			}
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,82 @@
+package ham
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestImages(t *testing.T) {
+	RegisterImageFormat("ham-test", ImageFormat{Ext: ".test", MimeType: "image/x-test", Encode: func(w io.Writer, img image.Image, quality int) error {
+		return png.Encode(w, img)
+	}})
+	t.Cleanup(func() { unregisterImageFormat("ham-test") })
+
+	photo := image.NewNRGBA(image.Rect(0, 0, 1000, 500))
+	for x := 0; x < 1000; x++ {
+		for y := 0; y < 500; y++ {
+			photo.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, photo); err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	src := testFS(map[string]string{
+		"ham.json":           `{"images": {"widths": [200, 600, 2000], "sizes": "50vw", "formats": ["ham-test"]}}`,
+		"src/blog/post.html": `<p><img src="../img/photo.png" alt="photo"><img src="/img/photo.png" sizes="10vw" srcset="a.png 1x"></p>`,
+		"src/img/photo.png":  buf.String(),
+	})
+	c, out := buildSite(t, src, Options{})
+
+	want := []string{
+		`<picture>` +
+			`<source type="image/x-test" srcset="/assets/img/img/photo-200.test 200w, /assets/img/img/photo-600.test 600w, /assets/img/img/photo-1000.test 1000w" sizes="50vw"/>` +
+			`<img src="/assets/img/img/photo.png" alt="photo" width="1000" height="500" ` +
+			`srcset="/assets/img/img/photo-200.png 200w, /assets/img/img/photo-600.png 600w, /assets/img/img/photo.png 1000w" sizes="50vw"/>` +
+			`</picture>`,
+		// images with their own srcset only get their size
+		`<img src="/img/photo.png" sizes="10vw" srcset="a.png 1x" width="1000" height="500"/>`,
+	}
+	if got := outerHTML(children(query(readDoc(t, out, "blog/post.html"), "p")[0])); !equal(got, want...) {
+		t.Errorf("images failed: expected\n%s\nbut got\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	for name, width := range map[string]int{"assets/img/img/photo-200.png": 200, "assets/img/img/photo-600.png": 600, "assets/img/img/photo.png": 1000} {
+		data, err := out.ReadFile(name)
+		if err != nil {
+			t.Errorf("images failed: %s not written", name)
+			continue
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || config.Width != width || config.Height != width/2 {
+			t.Errorf("images failed: expected %s to be %dx%d but got %dx%d (%v)", name, width, width/2, config.Width, config.Height, err)
+		}
+	}
+	if len(c.imageCache) != 5 {
+		t.Errorf("images failed: expected 5 cached variants but got %d", len(c.imageCache))
+	}
+
+	src["ham.json"] = &fstest.MapFile{Data: []byte(`{"images": {"formats": ["webp"]}}`)}
+	if _, err := NewFS(src, NewMemoryOutput(), Options{}); err == nil || !strings.Contains(err.Error(), "image format webp is not registered") {
+		t.Errorf("images failed: expected an unregistered format to be an error but got %v", err)
+	}
+	src["ham.json"] = &fstest.MapFile{Data: []byte(`{"images": {"widths": [200, 600, 2000], "sizes": "50vw", "formats": ["ham-test"]}}`)}
+
+	// rendering a page after the build links its variants without writing them
+	src["src/other.html"] = &fstest.MapFile{Data: []byte(`<img src="img/other.png">`)}
+	src["src/img/other.png"] = &fstest.MapFile{Data: buf.Bytes()}
+	files := len(out.Files())
+	if _, err := c.RenderPage("src/other.html"); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if len(out.Files()) != files {
+		t.Errorf("images failed: expected render after compile to write nothing but got %v", out.Files())
+	}
+}
@@ -0,0 +1,249 @@
+package ham
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// linkFromAttr marks the elements of partials and layouts with the file they came from while links are checked
+const linkFromAttr = "data-ham-from"
+
+// checkedLinkAttrs are the attributes whose urls CheckLinks follows, by element
+var checkedLinkAttrs = map[string][]string{
+	"a": {"href"}, "area": {"href"}, "link": {"href"},
+	"img": {"src", "srcset"}, "source": {"src", "srcset"}, "script": {"src"}, "iframe": {"src"},
+	"audio": {"src"}, "video": {"src", "poster"}, "track": {"src"}, "embed": {"src"},
+}
+
+// BrokenLink is a link of a compiled page whose target is not in the output
+type BrokenLink struct {
+	Page   string // output page, e.g. public/blog/index.html
+	Source string // source page, e.g. src/blog/index.html
+	From   string // partial or layout the link was written in. empty when it is in the page itself
+	Link   string // the link as it is in the page
+	Reason string
+}
+
+func (l BrokenLink) String() string {
+	where := l.Page
+	if l.Source != "" {
+		where += " (" + l.Source + ")"
+	}
+	from := ""
+	if l.From != "" {
+		from = " from " + l.From
+	}
+	return fmt.Sprintf("%s: broken link %q%s: %s", where, l.Link, from, l.Reason)
+}
+
+// LinkReport is the result of CheckLinks
+type LinkReport struct {
+	Pages    int          // compiled pages checked
+	Links    int          // internal links checked
+	Broken   []BrokenLink // internal links whose target is missing
+	External []string     // links to other sites, listed but never fetched
+}
+
+// CheckLinks compiles the site in memory and checks that the target of every internal href and src of its pages,
+// including the element of a #fragment, is a compiled page or a file in the output, e.g. an asset built by rollup.
+// html files and pages of earlier builds left in the output do not count, they are stale unless this build writes them.
+// links under one of the ignore prefixes, e.g. /api/, are skipped. nothing is written
+func (c *Compiler) CheckLinks(ignore []string) (*LinkReport, error) {
+	stale := make(map[string]bool)
+	for _, name := range c.previousPages() {
+		stale[name] = true
+	}
+	built := NewMemoryOutput()
+	out, opts := c.out, c.opts
+	c.out, c.traceLinks = built, true
+	c.opts.DryRun, c.opts.Diff, c.opts.Out = false, false, io.Discard
+	defer func() { c.out, c.opts, c.traceLinks = out, opts, false }()
+	if err := c.Compile(); err != nil {
+		return nil, err
+	}
+
+	exists := func(name string) bool {
+		if _, err := built.ReadFile(name); err == nil {
+			return true
+		}
+		if path.Ext(name) == ".html" || stale[name] {
+			return false
+		}
+		_, err := out.ReadFile(name)
+		return err == nil
+	}
+	read := func(name string) []byte {
+		b, _ := built.ReadFile(name)
+		return b
+	}
+	sources := make(map[string]string)
+	for _, ctx := range c.pages {
+		sources[ctx.OutPath] = ctx.SrcPath
+	}
+
+	report := &LinkReport{}
+	ids := make(map[string]map[string]bool)
+	external := make(map[string]bool)
+	for _, name := range built.Files() {
+		if path.Ext(name) != ".html" {
+			continue
+		}
+		doc, err := html.Parse(bytes.NewReader(read(name)))
+		if err != nil {
+			return nil, err
+		}
+		report.Pages++
+		ids[name] = elementIDs(doc)
+
+		walkNodes(doc, func(n *html.Node) {
+			if n.Type != html.ElementNode || skipLinkCheck(n) {
+				return
+			}
+			for _, key := range checkedLinkAttrs[n.Data] {
+				val := attr(n, key)
+				links := []string{val}
+				if key == "srcset" {
+					links = nil
+					for _, candidate := range strings.Split(val, ",") {
+						if fields := strings.Fields(candidate); len(fields) > 0 {
+							links = append(links, fields[0])
+						}
+					}
+				}
+				for _, link := range links {
+					link = strings.TrimSpace(link)
+					if link == "" {
+						continue
+					}
+					target, fragment, reason := c.linkTarget(name, link, ignore, exists)
+					switch {
+					case target == "" && reason == "":
+						continue // not a link to a file of the site
+					case reason == "external":
+						external[link] = true
+						continue
+					}
+					report.Links++
+					if reason == "" && fragment != "" && fragment != "top" && path.Ext(target) == ".html" {
+						if _, ok := ids[target]; !ok {
+							targetDoc, err := html.Parse(bytes.NewReader(read(target)))
+							if err == nil {
+								ids[target] = elementIDs(targetDoc)
+							}
+						}
+						if !ids[target][fragment] {
+							reason = fmt.Sprintf("%s has no element with id %s", target, fragment)
+						}
+					}
+					if reason != "" {
+						report.Broken = append(report.Broken, BrokenLink{Page: c.outputPath(name), Source: sources[name],
+							From: attr(n, linkFromAttr), Link: link, Reason: reason})
+					}
+				}
+			}
+		})
+	}
+
+	for link := range external {
+		report.External = append(report.External, link)
+	}
+	sort.Strings(report.External)
+	return report, nil
+}
+
+// linkTarget returns the output file link points to from the page name, with the fragment it points at.
+// reason tells why the link is broken, or is "external" for links to other sites. target and reason are both empty
+// for links that are not followed, such as mailto: links and ignored prefixes
+func (c *Compiler) linkTarget(name, link string, ignore []string, exists func(string) bool) (target, fragment, reason string) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", "", "malformed url"
+	}
+	switch {
+	case u.Scheme == "http" || u.Scheme == "https" || (u.Scheme == "" && u.Host != ""):
+		return "", "", "external"
+	case u.Scheme != "":
+		return "", "", "" // mailto:, tel:, data: and the like
+	case u.Path == "":
+		return name, u.Fragment, ""
+	}
+
+	p := (&url.URL{Path: "/" + name}).ResolveReference(&url.URL{Path: u.Path}).Path
+	for _, prefix := range ignore {
+		if prefix != "" && strings.HasPrefix(p, prefix) {
+			return "", "", ""
+		}
+	}
+	if base := c.config.BasePath; base != "" {
+		if p != base && !strings.HasPrefix(p, base+"/") {
+			return "", "", "outside the base path " + base
+		}
+		p = strings.TrimPrefix(p, base)
+	}
+
+	candidates := []string{p}
+	switch {
+	case p == "" || strings.HasSuffix(p, "/"):
+		candidates = []string{p + "/index.html"}
+	case path.Ext(p) == "":
+		candidates = append(candidates, p+".html", p+"/index.html") // served like the proxy serves pretty urls
+	}
+	for _, candidate := range candidates {
+		candidate = strings.TrimPrefix(path.Clean(candidate), "/")
+		if exists(candidate) {
+			return candidate, u.Fragment, ""
+		}
+	}
+	return "", "", strings.TrimPrefix(path.Clean(candidates[0]), "/") + " not found"
+}
+
+// skipLinkCheck reports whether the links of n are not followed: resource hints name origins, not files
+func skipLinkCheck(n *html.Node) bool {
+	if n.Data != "link" {
+		return false
+	}
+	for _, rel := range strings.Fields(strings.ToLower(attr(n, "rel"))) {
+		switch rel {
+		case "preconnect", "dns-prefetch":
+			return true
+		}
+	}
+	return false
+}
+
+// elementIDs returns the ids a url fragment can point at: id attributes and the names of <a> elements
+func elementIDs(doc *html.Node) map[string]bool {
+	ids := make(map[string]bool)
+	walkNodes(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		if id := attr(n, "id"); id != "" {
+			ids[id] = true
+		}
+		if n.Data == "a" && attr(n, "name") != "" {
+			ids[attr(n, "name")] = true
+		}
+	})
+	return ids
+}
+
+// markLinkSource records on the linking elements under root that they were written in from, unless an embed inside
+// it already did
+func markLinkSource(root *html.Node, from string) {
+	walkNodes(root, func(n *html.Node) {
+		if n.Type != html.ElementNode || hasAttr(n, linkFromAttr) {
+			return
+		}
+		if _, ok := checkedLinkAttrs[n.Data]; ok {
+			n.Attr = append(n.Attr, html.Attribute{Key: linkFromAttr, Val: from})
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package ham
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	c, disk := testSite(t, testFS(map[string]string{
+		"src/layout.lhtml": `<html><head><link rel="stylesheet" href="/assets/app.css"></head><body><embed type="ham/partial" src="partials/nav.phtml"/><embed type="ham/page"/></body></html>`,
+		"src/partials/nav.phtml": `<nav><a href="/">home</a><a href="../about.html">about</a><a href="/blog/#latest">blog</a>` +
+			`<a href="https://example.com">out</a><a href="mailto:me@example.com">mail</a><a href="/api/login">login</a></nav>`,
+		"src/index.html":      `<div data-ham-page-config='{"layout": "layout.lhtml"}'><img src="img/logo.png" srcset="img/logo.png 1x, img/logo@2x.png 2x"><a href="#top">top</a><a href="#intro">intro</a></div>`,
+		"src/blog/index.html": `<div data-ham-page-config='{"layout": "../layout.lhtml"}'><h2 id="latest">Latest</h2><a href="../index">back</a></div>`,
+	}), Options{})
+	disk.WriteFile("assets/app.css", []byte(`body{}`))
+	disk.WriteFile("img/logo.png", []byte(``))
+	disk.WriteFile("about.html", []byte(`<p>stale</p>`)) // left by an earlier build
+	report, err := c.CheckLinks([]string{"/api/"})
+	if err != nil {
+		t.Fatalf("check links failed: %v", err)
+	}
+
+	want := []string{
+		`blog/index.html (src/blog/index.html): broken link "../about.html" from src/partials/nav.phtml: about.html not found`,
+		`index.html (src/index.html): broken link "../about.html" from src/partials/nav.phtml: about.html not found`,
+		`index.html (src/index.html): broken link "img/logo@2x.png": img/logo@2x.png not found`,
+		`index.html (src/index.html): broken link "#intro": index.html has no element with id intro`,
+	}
+	var got []string
+	for _, link := range report.Broken {
+		got = append(got, link.String())
+	}
+	if !equal(got, want...) {
+		t.Errorf("check links failed: expected\n%s\nbut got\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if !equal(report.External, "https://example.com") || report.Pages != 2 {
+		t.Errorf("check links failed: expected 2 pages and one external link but got %d pages and %v", report.Pages, report.External)
+	}
+	if len(disk.Files()) != 3 {
+		t.Errorf("check links failed: expected nothing to be written but got %v", disk.Files())
+	}
+}
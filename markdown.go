@@ -0,0 +1,20 @@
+package ham
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown renders CommonMark. html written in the markdown is passed through as it is
+var markdown = goldmark.New(goldmark.WithRendererOptions(html.WithUnsafe()))
+
+// markdownToHTML renders markdown pages and collection items
+func markdownToHTML(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert(src, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
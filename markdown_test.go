@@ -0,0 +1,41 @@
+package ham
+
+import "testing"
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		md   string
+		html string
+	}{
+		{"# Title #", "<h1>Title</h1>\n"},
+		{"Some *em*, **strong** and `<code>`", "<p>Some <em>em</em>, <strong>strong</strong> and <code>&lt;code&gt;</code></p>\n"},
+		{"a [link](/about \"About\") and ![logo](/logo.png)", `<p>a <a href="/about" title="About">link</a> and <img src="/logo.png" alt="logo"></p>` + "\n"},
+		{"snake_case_name & 1 < 2 &amp; <b>bold</b>", "<p>snake_case_name &amp; 1 &lt; 2 &amp; <b>bold</b></p>\n"},
+		{"line  \nbreak", "<p>line<br>\nbreak</p>\n"},
+		{"- one\n- two\n  - nested\n\n1. first\n2. second", "<ul>\n<li>one</li>\n<li>two\n<ul>\n<li>nested</li>\n</ul>\n</li>\n</ul>\n<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n"},
+		{"- one\n\n- two", "<ul>\n<li>\n<p>one</p>\n</li>\n<li>\n<p>two</p>\n</li>\n</ul>\n"},
+		{"> quoted\ntext", "<blockquote>\n<p>quoted\ntext</p>\n</blockquote>\n"},
+		{"```go\nif a < b {}\n```", "<pre><code class=\"language-go\">if a &lt; b {}\n</code></pre>\n"},
+		{"    indented\n\n---", "<pre><code>indented\n</code></pre>\n<hr>\n"},
+		{"<div class=\"note\">\n*raw*\n</div>", "<div class=\"note\">\n*raw*\n</div>"},
+		{`\*not em\*`, "<p>*not em*</p>\n"},
+	}
+	for _, test := range tests {
+		got, err := markdownToHTML([]byte(test.md))
+		if err != nil || string(got) != test.html {
+			t.Errorf("markdown %q failed: expected %q but got %q (%v)", test.md, test.html, got, err)
+		}
+	}
+}
+
+func TestMarkdownPage(t *testing.T) {
+	_, out := buildSite(t, testFS(map[string]string{"src/blog/first.md": "---\ntitle: First post\n---\nHello *world*\n"}), Options{})
+
+	doc := readDoc(t, out, "blog/first.html")
+	if got := outerHTML(query(doc, "p")); !equal(got, "<p>Hello <em>world</em></p>") {
+		t.Errorf("markdown page failed: expected the paragraph but got %q", got)
+	}
+	if got := texts(query(doc, "title")); !equal(got, "First post") {
+		t.Errorf("markdown page failed: expected the title of the front matter but got %q", got)
+	}
+}
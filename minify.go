@@ -0,0 +1,410 @@
+package ham
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const preserveAttr = "data-ham-preserve" // elements carrying this attribute are never minified or formatted
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// blockElements are elements around which whitespace never renders
+var blockElements = map[string]bool{
+	"html": true, "head": true, "body": true, "title": true, "meta": true, "link": true, "style": true, "script": true, "base": true,
+	"address": true, "article": true, "aside": true, "blockquote": true, "details": true, "dialog": true, "dd": true, "div": true,
+	"dl": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hgroup": true, "hr": true, "li": true,
+	"main": true, "nav": true, "ol": true, "p": true, "section": true, "summary": true, "table": true, "caption": true,
+	"colgroup": true, "col": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "td": true, "th": true, "ul": true,
+	"noscript": true, "pre": true, "option": true, "optgroup": true, "select": true, "template": true,
+}
+
+// optionalEndTags maps elements whose end tag may be left out to the elements that may follow them when it is.
+// an empty follower means the end tag may also be left out when the element is the last in its parent
+var optionalEndTags = map[string][]string{
+	"li":     {"li", ""},
+	"dt":     {"dt", "dd"},
+	"dd":     {"dt", "dd", ""},
+	"option": {"option", "optgroup", ""},
+	"tr":     {"tr", ""},
+	"td":     {"td", "th", ""},
+	"th":     {"td", "th", ""},
+}
+
+var (
+	whitespace      = regexp.MustCompile(`[ \t\n\r\f]+`)
+	unquotedAttrVal = regexp.MustCompile("^[^ \t\n\r\f\"'=<>`/]+$")
+)
+
+// minifyHTML collapses whitespace, strips comments other than conditional and licence comments, leaves out optional
+// end tags and attribute quotes and minifies inline <style> and <script>. <pre>, <textarea> and elements marked
+// with data-ham-preserve are kept as they are
+func minifyHTML(content []byte) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := minifyNode(buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func minifyNode(buf *bytes.Buffer, n *html.Node) error {
+	switch n.Type {
+	case html.DocumentNode:
+		return minifyChildren(buf, n)
+	case html.DoctypeNode:
+		return html.Render(buf, n)
+	case html.CommentNode:
+		if keepComment(n.Data) {
+			buf.WriteString("<!--" + n.Data + "-->")
+		}
+		return nil
+	case html.TextNode:
+		minifyText(buf, n)
+		return nil
+	case html.ElementNode:
+	default:
+		return html.Render(buf, n)
+	}
+
+	if hasAttr(n, preserveAttr) || n.Data == "pre" || n.Data == "textarea" {
+		return html.Render(buf, n)
+	}
+
+	buf.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		buf.WriteByte(' ')
+		if a.Namespace != "" {
+			buf.WriteString(a.Namespace + ":")
+		}
+		buf.WriteString(a.Key)
+		switch {
+		case a.Val == "":
+		case unquotedAttrVal.MatchString(a.Val) && a.Key != "data-ham-proxy": // older ham proxies match it quoted
+			buf.WriteString("=" + strings.ReplaceAll(a.Val, "&", "&amp;"))
+		default:
+			buf.WriteString(`="` + strings.NewReplacer("&", "&amp;", `"`, "&quot;").Replace(a.Val) + `"`)
+		}
+	}
+	if n.Namespace != "" && n.FirstChild == nil {
+		buf.WriteString("/>") // foreign elements such as svg paths close themselves
+		return nil
+	}
+	buf.WriteByte('>')
+	if voidElements[n.Data] {
+		return nil
+	}
+
+	if err := minifyChildren(buf, n); err != nil {
+		return err
+	}
+	if !canOmitEndTag(n) {
+		buf.WriteString("</" + n.Data + ">")
+	}
+	return nil
+}
+
+func minifyChildren(buf *bytes.Buffer, n *html.Node) error {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if err := minifyNode(buf, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func minifyText(buf *bytes.Buffer, n *html.Node) {
+	if n.Parent != nil && n.Parent.Type == html.ElementNode {
+		switch n.Parent.Data {
+		case "style":
+			buf.WriteString(minifyCSS(n.Data))
+			return
+		case "script":
+			switch strings.ToLower(attrOr(n.Parent, "type", "text/javascript")) {
+			case "", "text/javascript", "module", "application/javascript":
+				buf.WriteString(minifyJS(n.Data))
+			default:
+				buf.WriteString(n.Data) // json, templates and other data blocks
+			}
+			return
+		case "noscript", "iframe", "xmp", "noembed", "noframes", "plaintext":
+			buf.WriteString(n.Data)
+			return
+		}
+	}
+
+	text := whitespace.ReplaceAllString(n.Data, " ")
+	if text == " " && isBlockBoundary(n.PrevSibling, n.Parent) && isBlockBoundary(n.NextSibling, n.Parent) {
+		return // whitespace between blocks is never rendered
+	}
+	buf.WriteString(escapeText(text))
+}
+
+// isBlockBoundary reports whether sibling, the node next to a whitespace text node, makes the whitespace insignificant
+func isBlockBoundary(sibling, parent *html.Node) bool {
+	if sibling == nil {
+		return parent == nil || parent.Type != html.ElementNode || blockElements[parent.Data]
+	}
+	switch sibling.Type {
+	case html.ElementNode:
+		return blockElements[sibling.Data]
+	case html.CommentNode, html.DoctypeNode:
+		return true
+	}
+	return false
+}
+
+func canOmitEndTag(n *html.Node) bool {
+	followers, ok := optionalEndTags[n.Data]
+	if !ok || n.Namespace != "" {
+		return false
+	}
+	next := n.NextSibling
+	for next != nil && next.Type == html.TextNode && whitespace.ReplaceAllString(next.Data, " ") == " " && isBlockBoundary(next.NextSibling, n.Parent) {
+		next = next.NextSibling // dropped by minifyText
+	}
+	for _, f := range followers {
+		switch {
+		case f == "" && next == nil:
+			return true
+		case next != nil && next.Type == html.ElementNode && next.Data == f:
+			return true
+		}
+	}
+	return false
+}
+
+func keepComment(comment string) bool {
+	c := strings.TrimSpace(comment)
+	return strings.HasPrefix(c, "[if") || strings.HasSuffix(c, "<![endif]") || strings.HasPrefix(c, "!") ||
+		strings.Contains(c, "@license") || strings.Contains(c, "@preserve")
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\u00a0", "&nbsp;").Replace(s)
+}
+
+// minifyCSS strips comments, except /*! licence comments, and whitespace that does not change the meaning of the stylesheet
+func minifyCSS(css string) string {
+	var out []byte
+	space := false
+	for i := 0; i < len(css); i++ {
+		ch := css[i]
+		switch {
+		case ch == '/' && i+1 < len(css) && css[i+1] == '*':
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				i = len(css) // unterminated comment runs to the end
+				continue
+			}
+			if i+2 < len(css) && css[i+2] == '!' {
+				out = append(out, css[i:i+2+end+2]...)
+			}
+			i += 2 + end + 1
+			continue
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f':
+			space = true
+			continue
+		}
+
+		// a single space is kept unless the characters either side of it make it redundant
+		if space && len(out) > 0 && strings.IndexByte("{};,>:", out[len(out)-1]) < 0 && strings.IndexByte("{};,>!", ch) < 0 &&
+			!(ch == ':' && cssDeclarationColon(css, i)) {
+			out = append(out, ' ')
+		}
+		space = false
+
+		switch ch {
+		case '"', '\'':
+			j := skipString(css, i)
+			out = append(out, css[i:j]...)
+			i = j - 1
+		case '}':
+			if len(out) > 0 && out[len(out)-1] == ';' {
+				out = out[:len(out)-1]
+			}
+			out = append(out, ch)
+		default:
+			out = append(out, ch)
+		}
+	}
+	return string(out)
+}
+
+// cssDeclarationColon reports whether the : at css[i] separates a property from its value. in a selector, where
+// "a :hover" differs from "a:hover", it is followed by the { of a block before any ; or }
+func cssDeclarationColon(css string, i int) bool {
+	end := strings.IndexAny(css[i:], "{;}")
+	return end < 0 || css[i+end] != '{'
+}
+
+// skipString returns the index just past the quoted string starting at s[i]
+func skipString(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// minifyJS removes comments, except /*! licence comments, and collapses whitespace. line breaks are kept
+// so automatic semicolon insertion still applies. strings, template literals and regular expressions are left alone
+func minifyJS(js string) string {
+	var out strings.Builder
+	space, newline := false, false
+	flush := func() {
+		if out.Len() > 0 {
+			if newline {
+				out.WriteByte('\n')
+			} else if space {
+				out.WriteByte(' ')
+			}
+		}
+		space, newline = false, false
+	}
+
+	for i := 0; i < len(js); i++ {
+		ch := js[i]
+		switch {
+		case ch == '/' && i+1 < len(js) && js[i+1] == '/':
+			end := strings.IndexByte(js[i:], '\n')
+			if end < 0 {
+				end = len(js) - i
+			}
+			i += end - 1
+			continue
+		case ch == '/' && i+1 < len(js) && js[i+1] == '*':
+			end := strings.Index(js[i+2:], "*/")
+			if end < 0 {
+				i = len(js) // unterminated comment runs to the end
+				continue
+			}
+			if i+2 < len(js) && js[i+2] == '!' {
+				flush()
+				out.WriteString(js[i : i+2+end+2])
+			} else if strings.Contains(js[i:i+2+end], "\n") {
+				newline = true
+			} else {
+				space = true
+			}
+			i += 2 + end + 1
+			continue
+		case ch == '\n' || ch == '\r':
+			newline = true
+			continue
+		case ch == ' ' || ch == '\t' || ch == '\f':
+			space = true
+			continue
+		}
+
+		flush()
+		switch {
+		case ch == '"' || ch == '\'':
+			j := skipString(js, i)
+			out.WriteString(js[i:j])
+			i = j - 1
+		case ch == '`':
+			j := skipTemplate(js, i)
+			out.WriteString(js[i:j])
+			i = j - 1
+		case ch == '/' && regexAllowed(regexContext(out.String())):
+			j := skipRegex(js, i)
+			out.WriteString(js[i:j])
+			i = j - 1
+		default:
+			out.WriteByte(ch)
+		}
+	}
+	return out.String()
+}
+
+// skipTemplate returns the index just past the template literal starting at s[i]
+func skipTemplate(s string, i int) int {
+	depth := 0
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case s[j] == '\\':
+			j++
+		case depth == 0 && s[j] == '`':
+			return j + 1
+		case s[j] == '$' && j+1 < len(s) && s[j+1] == '{':
+			depth++
+			j++
+		case depth > 0 && s[j] == '}':
+			depth--
+		case depth > 0 && (s[j] == '"' || s[j] == '\''):
+			j = skipString(s, j) - 1
+		}
+	}
+	return len(s)
+}
+
+// skipRegex returns the index just past the regular expression literal starting at s[i]
+func skipRegex(s string, i int) int {
+	class := false
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '\n':
+			return j // not a regular expression after all
+		case '/':
+			if !class {
+				return j + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// regexContext returns the end of the minified output regexAllowed needs: the longest keyword it looks for and the
+// byte before it, so a / is checked in constant time however long the script is
+func regexContext(before string) string {
+	if len(before) > 8 {
+		return before[len(before)-8:]
+	}
+	return before
+}
+
+// regexAllowed reports whether a / following the minified output so far starts a regular expression rather than a division
+func regexAllowed(before string) bool {
+	before = strings.TrimRight(before, " \n")
+	if before == "" {
+		return true
+	}
+	if strings.IndexByte("(,=:[!&|?{};+-*%<>~^", before[len(before)-1]) >= 0 {
+		return true
+	}
+	for _, keyword := range []string{"return", "typeof", "case", "do", "else", "in", "of", "new", "delete", "void", "throw", "yield", "await"} {
+		if strings.HasSuffix(before, keyword) {
+			rest := before[:len(before)-len(keyword)]
+			if rest == "" || !isIdentChar(rest[len(rest)-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
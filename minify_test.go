@@ -0,0 +1,77 @@
+package ham
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMinifyHTML(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <title>  Minify   me </title>
+  <!-- build comment -->
+  <!--[if IE]><p>old</p><![endif]-->
+  <!--! licence -->
+  <style>
+    /* layout */
+    .a  >  .b { color: red ;  margin: 0 auto; }
+    .c::before { content: "  keep  "; }
+    .d { color : blue }
+    .e :hover { color : green ; }
+  </style>
+</head>
+<body>
+  <div class="page  main" hidden="">
+    <p>Some    <b>bold</b>   text</p>
+    <ul>
+      <li>one</li>
+      <li>two</li>
+    </ul>
+    <pre>  keep
+    this  </pre>
+    <textarea>  and   this </textarea>
+    <div data-ham-preserve>   <span>  preserved  </span>   </div>
+    <div data-ham-proxy="requires-authentication" class="private">account</div>
+  </div>
+  <script>
+    // comment
+    const s = "a  // not a comment";
+    const re = /\/ +/g;
+    function half(n) { return /\d/.test(n) ? n / 2 / 1 : 0; }
+    const t = ` + "`x  ${ 1 + 1 }  y`" + `;
+  </script>
+</body>
+</html>`
+
+	b, err := minifyHTML([]byte(page))
+	if err != nil {
+		t.Fatalf("minify failed: %v", err)
+	}
+	out := string(b)
+
+	for _, want := range []string{
+		`<!DOCTYPE html><html lang=en><head><title> Minify me </title>`,
+		`<!--[if IE]><p>old</p><![endif]-->`,
+		`<!--! licence -->`,
+		`<style>.a>.b{color:red;margin:0 auto}.c::before{content:"  keep  "}.d{color:blue}.e :hover{color:green}</style>`,
+		`<div class="page  main" hidden><p>Some <b>bold</b> text</p><ul><li>one<li>two</ul>`,
+		"<pre>  keep\n    this  </pre>",
+		`<textarea>  and   this </textarea>`,
+		`<div data-ham-preserve="">   <span>  preserved  </span>   </div>`,
+		`<div data-ham-proxy="requires-authentication" class=private>account</div>`,
+		`const s = "a  // not a comment";`,
+		`const re = /\/ +/g;`,
+		`function half(n) { return /\d/.test(n) ? n / 2 / 1 : 0; }`,
+		"const t = `x  ${ 1 + 1 }  y`;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("minify failed: expected output to contain %s in\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"build comment", "// comment", "/* layout */"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("minify failed: expected %s to be stripped from\n%s", unwanted, out)
+		}
+	}
+}
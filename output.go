@@ -0,0 +1,124 @@
+package ham
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output receives the files produced by a build. names are slash separated and relative to the output root
+type Output interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+	Remove(name string) error
+}
+
+// DirOutput writes compiled files into a directory on disk
+type DirOutput struct {
+	Dir string
+}
+
+func NewDirOutput(dir string) *DirOutput {
+	return &DirOutput{Dir: dir}
+}
+
+func (d *DirOutput) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(d.path(name))
+}
+
+func (d *DirOutput) WriteFile(name string, data []byte) error {
+	p := d.path(name)
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, os.ModePerm)
+}
+
+func (d *DirOutput) Remove(name string) error {
+	return os.Remove(d.path(name))
+}
+
+func (d *DirOutput) path(name string) string {
+	return filepath.Join(d.Dir, filepath.FromSlash(name))
+}
+
+// MemoryOutput keeps compiled files in memory. It can serve them directly as an http.Handler
+type MemoryOutput struct {
+	mu      sync.RWMutex
+	files   map[string][]byte
+	modTime time.Time
+}
+
+func NewMemoryOutput() *MemoryOutput {
+	return &MemoryOutput{files: make(map[string][]byte)}
+}
+
+func (m *MemoryOutput) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.files[path.Clean(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: name, Err: os.ErrNotExist}
+	}
+	return b, nil
+}
+
+func (m *MemoryOutput) WriteFile(name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[path.Clean(name)] = append([]byte{}, data...)
+	m.modTime = time.Now()
+	return nil
+}
+
+func (m *MemoryOutput) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[path.Clean(name)]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(m.files, path.Clean(name))
+	return nil
+}
+
+// Files returns the names of all files written so far, sorted
+func (m *MemoryOutput) Files() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP serves compiled files, resolving directory requests to their index.html
+func (m *MemoryOutput) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	candidates := []string{name}
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+		candidates = []string{path.Join(name, "index.html")}
+	} else if path.Ext(name) == "" {
+		candidates = append(candidates, name+".html", path.Join(name, "index.html"))
+	}
+
+	for _, candidate := range candidates {
+		b, err := m.ReadFile(candidate)
+		if err != nil {
+			continue
+		}
+		m.mu.RLock()
+		modTime := m.modTime
+		m.mu.RUnlock()
+		http.ServeContent(w, r, candidate, modTime, bytes.NewReader(b))
+		return
+	}
+	http.NotFound(w, r)
+}
@@ -0,0 +1,170 @@
+package ham
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const paginationIfAttr = "data-ham-if"
+
+// PaginateConfig splits a listing page into pages of Size items, e.g. "paginate": {"data": "collections.blog", "size": 10}
+type PaginateConfig struct {
+	Data string `json:"data"` // collections.<name> or data.<name> of ham.json
+	Size int    `json:"size"`
+}
+
+// Pagination describes the page of a paginated listing being compiled
+type Pagination struct {
+	Number int    // page number, starting at 1
+	Total  int    // number of pages
+	Prev   string // url of the previous page, empty on the first page
+	Next   string // url of the next page, empty on the last page
+	items  []*collectionItem
+}
+
+// paginate sets the pagination of a page whose config has paginate. the page number is taken from ctx.Pagination when set
+func (c *Compiler) paginate(ctx *PageContext) error {
+	conf := ctx.Page.Layout.Paginate
+	items, err := c.dataItems(conf.Data)
+	if err != nil {
+		return fmt.Errorf("failed to compile %s. %v", ctx.SrcPath, err)
+	}
+	size := conf.Size
+	if size <= 0 {
+		size = len(items)
+	}
+	total := 1
+	if size > 0 && len(items) > size {
+		total = (len(items) + size - 1) / size
+	}
+
+	number := 1
+	if ctx.Pagination != nil {
+		number = ctx.Pagination.Number
+	}
+	start, end := (number-1)*size, number*size
+	if start > len(items) {
+		start = len(items)
+	}
+	if end > len(items) {
+		end = len(items)
+	}
+
+	firstPage, err := c.pageOutPath(ctx.SrcPath, ctx.Page.Data)
+	if err != nil {
+		return err
+	}
+	p := &Pagination{Number: number, Total: total, items: items[start:end]}
+	if number > 1 {
+		p.Prev = c.url("/" + pageURLPath(paginatedOutPath(firstPage, number-1)))
+	}
+	if number < total {
+		p.Next = c.url("/" + pageURLPath(paginatedOutPath(firstPage, number+1)))
+	}
+	ctx.Pagination = p
+	return nil
+}
+
+// paginatedOutPath returns the output file of page number of a listing: blog/index.html is followed by blog/page/2/index.html
+func paginatedOutPath(firstPage string, number int) string {
+	if number <= 1 {
+		return firstPage
+	}
+	prefix := strings.TrimSuffix(firstPage, ".html")
+	if path.Base(firstPage) == "index.html" {
+		prefix = path.Dir(firstPage)
+	}
+	return strings.TrimPrefix(path.Join(prefix, "page", strconv.Itoa(number), "index.html"), "./")
+}
+
+// dataItems returns the items of collections.<name>, data.<name>, taxonomies.<name> or a bare collection name
+func (c *Compiler) dataItems(ref string) ([]*collectionItem, error) {
+	if strings.HasPrefix(ref, "taxonomies.") {
+		return c.taxonomy(strings.TrimPrefix(ref, "taxonomies."))
+	}
+	if !strings.HasPrefix(ref, "data.") {
+		return c.collection(strings.TrimPrefix(ref, "collections."))
+	}
+
+	name := strings.TrimPrefix(ref, "data.")
+	raw, ok := c.config.Data[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown data %s", name)
+	}
+	// data is written in ham.json or kept in a json file of the project
+	var file string
+	if json.Unmarshal(raw, &file) == nil {
+		if raw = c.readFile(file); raw == nil {
+			return nil, fmt.Errorf("data file %s not found", file)
+		}
+	}
+	var values []interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("data %s is not an array: %v", name, err)
+	}
+
+	var items []*collectionItem
+	for _, v := range values {
+		data, ok := v.(map[string]interface{})
+		if !ok {
+			data = map[string]interface{}{"value": v}
+		}
+		items = append(items, &collectionItem{
+			URL:     stringValue(data["url"]),
+			Title:   stringValue(data["title"]),
+			Date:    parseDate(data["date"]),
+			Summary: stringValue(data["description"]),
+			Content: stringValue(data["content"]),
+			Data:    data,
+		})
+	}
+	return items, nil
+}
+
+// applyPaginationIf removes elements marked data-ham-if="prev" or data-ham-if="next" when there is no such page
+func applyPaginationIf(doc *html.Node, p *Pagination) {
+	var marked []*html.Node
+	walkNodes(doc, func(n *html.Node) {
+		if n.Type == html.ElementNode && hasAttr(n, paginationIfAttr) {
+			marked = append(marked, n)
+		}
+	})
+	for _, n := range marked {
+		keep := true
+		switch attr(n, paginationIfAttr) {
+		case "prev":
+			keep = p != nil && p.Prev != ""
+		case "next":
+			keep = p != nil && p.Next != ""
+		}
+		if !keep {
+			n.Parent.RemoveChild(n)
+			continue
+		}
+		var kept []html.Attribute
+		for _, a := range n.Attr {
+			if a.Key != paginationIfAttr {
+				kept = append(kept, a)
+			}
+		}
+		n.Attr = kept
+	}
+}
+
+// replacePagination fills the {ham:prev}, {ham:next}, {ham:page-number} and {ham:total-pages} placeholders of a page
+func replacePagination(pageHTML []byte, p *Pagination) []byte {
+	if p == nil {
+		p = &Pagination{Number: 1, Total: 1}
+	}
+	return []byte(strings.NewReplacer(
+		"{ham:prev}", html.EscapeString(p.Prev),
+		"{ham:next}", html.EscapeString(p.Next),
+		"{ham:page-number}", strconv.Itoa(p.Number),
+		"{ham:total-pages}", strconv.Itoa(p.Total),
+	).Replace(string(pageHTML)))
+}
@@ -0,0 +1,41 @@
+package ham
+
+import "testing"
+
+func TestPagination(t *testing.T) {
+	_, out := buildSite(t, testFS(map[string]string{
+		"ham.json":      `{"data": {"team": "src/team.json"}}`,
+		"src/team.json": `[{"title": "Ann"}, {"title": "Bob"}, {"title": "Cid"}, {"title": "Dee"}, {"title": "Eve"}]`,
+		"src/team/index.html": `---
+paginate:
+  data: data.team
+  size: 2
+---
+<ul><embed type="ham/collection" src="../member.phtml"/></ul>
+<p>{ham:page-number} of {ham:total-pages}</p><a data-ham-if="prev" href="{ham:prev}">prev</a><a data-ham-if="next" href="{ham:next}">next</a>`,
+		"src/member.phtml": `<li>__title__</li>`,
+	}), Options{})
+
+	tests := []struct {
+		page    string
+		members []string
+		number  string
+		links   []string
+	}{
+		{"team/index.html", []string{"Ann", "Bob"}, "1 of 3", []string{"next /team/page/2/"}},
+		{"team/page/2/index.html", []string{"Cid", "Dee"}, "2 of 3", []string{"prev /team/", "next /team/page/3/"}},
+		{"team/page/3/index.html", []string{"Eve"}, "3 of 3", []string{"prev /team/page/2/"}},
+	}
+	for _, test := range tests {
+		doc := readDoc(t, out, test.page)
+		if got := texts(query(doc, "li")); !equal(got, test.members...) {
+			t.Errorf("pagination of %s failed: expected the members %q but got %q", test.page, test.members, got)
+		}
+		if got := texts(query(doc, "p")); !equal(got, test.number) {
+			t.Errorf("pagination of %s failed: expected %s but got %q", test.page, test.number, got)
+		}
+		if got := links(doc); !equal(got, test.links...) {
+			t.Errorf("pagination of %s failed: expected the links %q but got %q", test.page, test.links, got)
+		}
+	}
+}
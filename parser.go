@@ -5,28 +5,38 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync/atomic"
 
 	"golang.org/x/net/html"
 )
 
 type Layout struct {
-	Src    string   `json:"layout"`
-	Path   string   `json:"path"`
-	CSS    []string `json:"css,omitempty"`
-	Js     []string `json:"js,omitempty"`
-	JsMod  []string `json:"js-mod,omitempty"`
-	Embeds []Embed
+	Src         string          `json:"layout"`
+	Path        string          `json:"path"`
+	CSS         []Resource      `json:"css,omitempty"`
+	Js          []Resource      `json:"js,omitempty"`
+	JsMod       []Resource      `json:"js-mod,omitempty"`
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Image       string          `json:"og:image,omitempty"`
+	Canonical   string          `json:"canonical,omitempty"`
+	Sitemap     *PageSitemap    `json:"sitemap,omitempty"`
+	Paginate    *PaginateConfig `json:"paginate,omitempty"`
+	Embeds      []Embed
 }
 
 type Page struct {
 	Layout *Layout
+	Data   map[string]interface{} // page config and front matter as they were written
 	Embeds []Embed
 }
 
 type Embed struct {
-	Type    string
-	Src     string
-	Replace string
+	Type        string
+	Src         string
+	Replace     string
+	Attrs       map[string]string // all attributes of the <embed> tag
+	placeholder string            // text the embed was replaced with in the document
 }
 
 func ParseLayout(doc *html.Node) Layout {
@@ -51,29 +61,25 @@ func parseLayout(start *html.Node, layout *Layout) {
 				}
 			}
 		case "embed":
-			em := Embed{}
-			for _, attr := range start.Attr {
-				switch attr.Key {
-				case "type":
-					em.Type = attr.Val
-				case "src":
-					em.Src = attr.Val
-				case "data-ham-replace":
-					em.Replace = attr.Val
-				}
+			em := parseEmbed(start)
+			if !isHamEmbed(em.Type) {
+				break
 			}
-			layout.Embeds = append(layout.Embeds, em)
 			start.Type = 1
 			switch em.Type {
 			case "ham/partial":
-				start.Data = embedPlaceholder(em.Src)
+				em.placeholder = embedPlaceholder(em.Src)
 			case "ham/page":
-				start.Data = "{ham:page}"
+				em.placeholder = "{ham:page}"
 			case "ham/layout-js":
-				start.Data = "{ham:js}"
+				em.placeholder = "{ham:js}"
 			case "ham/layout-css":
-				start.Data = "{ham:css}"
+				em.placeholder = "{ham:css}"
+			default:
+				em.placeholder = customEmbedPlaceholder(em.Type)
 			}
+			start.Data = em.placeholder
+			layout.Embeds = append(layout.Embeds, em)
 		}
 	}
 
@@ -97,26 +103,21 @@ func parsePage(start *html.Node, page *Page) {
 		for _, attr := range start.Attr {
 			if attr.Key == "class" && strings.Contains(attr.Val, "ham-remove") {
 				start.Parent.RemoveChild(start)
+				return
 			}
 		}
 		switch start.Data {
 		case "embed":
-			em := Embed{}
-			for _, attr := range start.Attr {
-				switch attr.Key {
-				case "type":
-					em.Type = attr.Val
-				case "src":
-					em.Src = attr.Val
-				case "data-ham-replace":
-					em.Replace = attr.Val
-				}
+			em := parseEmbed(start)
+			if !isHamEmbed(em.Type) {
+				break
 			}
 			start.Type = 1
 			switch em.Type {
 			case "ham/partial":
 				// replace <embed> tag with placeholders
-				start.Data = embedPlaceholder(em.Src)
+				em.placeholder = embedPlaceholder(em.Src)
+				start.Data = em.placeholder
 				page.Embeds = append(page.Embeds, em)
 			case "ham/page":
 				start.Data = "{ham:page}"
@@ -124,6 +125,10 @@ func parsePage(start *html.Node, page *Page) {
 				start.Data = "{ham:js}"
 			case "ham/layout-css":
 				start.Data = "{ham:css}"
+			default:
+				em.placeholder = customEmbedPlaceholder(em.Type)
+				start.Data = em.placeholder
+				page.Embeds = append(page.Embeds, em)
 			}
 		case "div":
 			var newAttr []html.Attribute
@@ -134,6 +139,7 @@ func parsePage(start *html.Node, page *Page) {
 						log.Println("config decode error", err.Error())
 						continue
 					}
+					json.Unmarshal([]byte(attr.Val), &page.Data)
 				default:
 					newAttr = append(newAttr, attr)
 				}
@@ -142,9 +148,44 @@ func parsePage(start *html.Node, page *Page) {
 		}
 	}
 
-	for start = start.FirstChild; start != nil; start = start.NextSibling {
-		parsePage(start, page)
+	for child := start.FirstChild; child != nil; {
+		next := child.NextSibling // child may remove itself
+		parsePage(child, page)
+		child = next
+	}
+}
+
+func parseEmbed(n *html.Node) Embed {
+	em := Embed{Attrs: make(map[string]string)}
+	for _, attr := range n.Attr {
+		em.Attrs[attr.Key] = attr.Val
+		switch attr.Key {
+		case "type":
+			em.Type = attr.Val
+		case "src":
+			em.Src = attr.Val
+		case "data-ham-replace":
+			em.Replace = attr.Val
+		}
 	}
+	return em
+}
+
+// isHamEmbed reports whether an <embed> is handled by HAM. embeds of other types, e.g. video/mp4, are plain html and left alone.
+// registered embed types are HAM embeds whatever their name
+func isHamEmbed(typ string) bool {
+	if strings.HasPrefix(typ, "ham/") {
+		return true
+	}
+	_, ok := lookupEmbedType(typ)
+	return ok
+}
+
+var embedSeq uint64
+
+// customEmbedPlaceholder returns a placeholder unique to one embed, so every embed of a custom type is rendered on its own
+func customEmbedPlaceholder(typ string) string {
+	return embedPlaceholder(fmt.Sprintf("%s#%d", typ, atomic.AddUint64(&embedSeq, 1)))
 }
 
 func embedPlaceholder(src string) string {
@@ -0,0 +1,98 @@
+package ham
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/fobilow/ham/helper"
+)
+
+const (
+	permalinkPretty = "pretty" // src/about.html is written to about/index.html and served as /about/
+)
+
+var permalinkToken = regexp.MustCompile(`:[a-z]+`)
+
+// pageOutPath returns the output file of a source page. data is the page config and front matter of the page,
+// its permalink setting wins over the one of ham.json
+//
+//	""                   src/blog/post.md -> blog/post.html
+//	"pretty"             src/blog/post.md -> blog/post/index.html
+//	"/blog/:year/:slug/" src/blog/post.md -> blog/2024/post/index.html
+//
+// patterns can use :year, :month, :day (from the date), :slug (the slug or file name), :title, :path
+// (the directory below src) and :name (the file name). a pattern ending in / is written as index.html.
+// unknown tokens and date tokens of a page without a date are an error, they are never written as they are
+func (c *Compiler) pageOutPath(srcPath string, data map[string]interface{}) (string, error) {
+	permalink := c.config.Permalink
+	if p, ok := data["permalink"].(string); ok {
+		permalink = p
+	}
+
+	pageDir, ok := srcRelative(path.Dir(srcPath))
+	if !ok {
+		pageDir = path.Dir(srcPath)
+	}
+	name := strings.TrimSuffix(path.Base(srcPath), path.Ext(srcPath))
+
+	switch permalink {
+	case "":
+		return path.Join(pageDir, name+".html"), nil
+	case permalinkPretty:
+		if name == "index" {
+			return path.Join(pageDir, "index.html"), nil
+		}
+		return path.Join(pageDir, name, "index.html"), nil
+	}
+
+	date := parseDate(data["date"])
+	slug := stringValue(data["slug"])
+	if slug == "" {
+		slug = name
+	}
+	title := helper.Slugify(stringValue(data["title"]))
+	if title == "" {
+		title = slug
+	}
+	values := map[string]string{":slug": slug, ":title": title, ":path": pageDir, ":name": name, ":year": "", ":month": "", ":day": ""}
+	if !date.IsZero() {
+		values[":year"] = fmt.Sprintf("%04d", date.Year())
+		values[":month"] = fmt.Sprintf("%02d", date.Month())
+		values[":day"] = fmt.Sprintf("%02d", date.Day())
+	}
+	var err error
+	out := permalinkToken.ReplaceAllStringFunc(permalink, func(token string) string {
+		v, ok := values[token]
+		switch {
+		case err != nil:
+		case !ok:
+			err = fmt.Errorf("permalink %s has an unknown token %s", permalink, token)
+		case v == "" && token != ":path":
+			err = fmt.Errorf("permalink %s uses %s but the page has no date", permalink, token)
+		}
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+
+	dir := strings.HasSuffix(out, "/")
+	out = strings.TrimPrefix(path.Clean("/"+out), "/")
+	switch {
+	case dir || out == "":
+		return path.Join(out, "index.html"), nil
+	case path.Ext(out) == "":
+		return out + ".html", nil
+	}
+	return out, nil
+}
+
+// pageURLPath returns the url path of an output page, without the leading slash. index pages are served as their directory
+func pageURLPath(outPath string) string {
+	if path.Base(outPath) == "index.html" {
+		return strings.TrimSuffix(outPath, "index.html")
+	}
+	return outPath
+}
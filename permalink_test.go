@@ -0,0 +1,52 @@
+package ham
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPermalinks(t *testing.T) {
+	src := testFS(map[string]string{
+		"ham.json":          `{"permalink": "pretty", "baseUrl": "https://example.com", "collections": {"blog": {"glob": "src/blog/*.md"}}}`,
+		"src/index.html":    `<ul><embed type="ham/collection" data-collection="blog" src="item.phtml"/></ul>`,
+		"src/about.html":    `<p>about</p>`,
+		"src/contact.html":  `<div data-ham-page-config='{"permalink": ""}'>contact</div>`,
+		"src/blog/hello.md": "---\npermalink: /blog/:year/:month/:slug/\ndate: 2024-03-09\n---\nhello",
+		"src/blog/feed.md":  "---\npermalink: /blog/:title.xml\ntitle: All Posts\n---\nall",
+		"src/item.phtml":    `<li>__url__</li>`,
+	})
+	c, out := buildSite(t, src, Options{})
+
+	for _, name := range []string{"index.html", "about/index.html", "contact.html", "blog/2024/03/hello/index.html", "blog/all-posts.xml"} {
+		if _, err := out.ReadFile(name); err != nil {
+			t.Errorf("permalinks failed: %s not written, got %v", name, out.Files())
+		}
+	}
+	if got := texts(query(readDoc(t, out, "index.html"), "li")); !equal(got, "/blog/2024/03/hello/", "/blog/all-posts.xml") {
+		t.Errorf("permalinks failed: expected the collection urls to follow the permalinks but got %q", got)
+	}
+	var locs []string
+	for _, u := range readSitemap(t, out, "sitemap.xml").URLs {
+		locs = append(locs, strings.TrimPrefix(u.Loc, "https://example.com"))
+	}
+	if !equal(locs, "/", "/about/", "/blog/2024/03/hello/", "/blog/all-posts.xml", "/contact.html") {
+		t.Errorf("permalinks failed: expected the sitemap urls to follow the permalinks but got %q", locs)
+	}
+
+	src["src/about.md"] = &fstest.MapFile{Data: []byte("about again")}
+	if err := c.Compile(); err == nil || !strings.Contains(err.Error(), "src/about.md. about/index.html is also written by src/about.html") {
+		t.Errorf("permalinks failed: expected an error for two pages written to about/index.html but got %v", err)
+	}
+	delete(src, "src/about.md")
+
+	for permalink, want := range map[string]string{
+		"/blog/:year/:slug/": "permalink /blog/:year/:slug/ uses :year but the page has no date",
+		"/blog/:author/":     "permalink /blog/:author/ has an unknown token :author",
+	} {
+		src["src/blog/undated.md"] = &fstest.MapFile{Data: []byte("---\npermalink: " + permalink + "\n---\nundated")}
+		if err := c.Compile(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("permalinks failed: expected %q but got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package ham
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fobilow/ham/helper"
+	"golang.org/x/net/html"
+)
+
+// Plugin extends the compile pipeline. A plugin implements one or more of the hook interfaces below
+// and is enabled by listing its name under "plugins" in ham.json
+type Plugin interface {
+	Name() string
+}
+
+// PageContext describes the page going through the pipeline
+type PageContext struct {
+	SrcPath string // source page, relative to the project root
+	OutPath string // output file, relative to the output root. empty when the page is only rendered
+	Page    *Page  // page metadata, available from AfterParsePage
+
+	Pagination *Pagination // set on pages with a paginate config
+
+	items        []*collectionItem // listed by ham/collection embeds of a generated page
+	permalink    bool              // OutPath follows the permalink of the page, set once its config is read
+	requiresAuth bool              // the page is marked data-ham-proxy="requires-authentication"
+	csp          string            // content security policy of the page when it is written to _headers
+	embedCount   map[string]int    // custom embeds rendered so far on the page, by type
+}
+
+// BuildContext describes a finished build
+type BuildContext struct {
+	Pages  []*PageContext
+	Output Output
+}
+
+// BeforeParseHook receives the raw page source before it is parsed
+type BeforeParseHook interface {
+	BeforeParse(ctx *PageContext, src []byte) ([]byte, error)
+}
+
+// AfterParsePageHook receives the page tree once ParsePage has read the page config and embeds
+type AfterParsePageHook interface {
+	AfterParsePage(ctx *PageContext, doc *html.Node) error
+}
+
+// AfterLayoutHook receives the final document once the page has been merged into its layout and all partials are embedded
+type AfterLayoutHook interface {
+	AfterLayout(ctx *PageContext, doc *html.Node) error
+}
+
+// BeforeWriteHook receives the rendered page before it is written
+type BeforeWriteHook interface {
+	BeforeWrite(ctx *PageContext, content []byte) ([]byte, error)
+}
+
+// AfterBuildHook runs once all pages have been written
+type AfterBuildHook interface {
+	AfterBuild(ctx *BuildContext) error
+}
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   = make(map[string]Plugin)
+)
+
+// RegisterPlugin makes a plugin available to ham.json by name. It panics if the name is already registered
+func RegisterPlugin(p Plugin) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	if p == nil {
+		panic("ham: RegisterPlugin plugin is nil")
+	}
+	if _, dup := plugins[p.Name()]; dup {
+		panic("ham: RegisterPlugin called twice for plugin " + p.Name())
+	}
+	plugins[p.Name()] = p
+}
+
+// Plugins returns the names of all registered plugins
+func Plugins() []string {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	var names []string
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// enabledPlugins returns the plugins names lists, looked up in own before the registered plugins
+func enabledPlugins(names []string, own []Plugin) ([]Plugin, error) {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	var enabled []Plugin
+	for _, name := range names {
+		p, ok := plugins[name]
+		for _, o := range own {
+			if o != nil && o.Name() == name {
+				p, ok = o, true
+				break
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown plugin %q in %s", name, configFileName)
+		}
+		enabled = append(enabled, p)
+	}
+	return enabled, nil
+}
+
+func init() {
+	RegisterPlugin(headingIDs{})
+}
+
+// headingIDs gives every heading without an id one derived from its text, so headings can be linked to
+type headingIDs struct{}
+
+func (headingIDs) Name() string {
+	return "heading-ids"
+}
+
+func (headingIDs) AfterLayout(ctx *PageContext, doc *html.Node) error {
+	used := make(map[string]bool)
+	var headings []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id := attr(n, "id"); id != "" {
+				used[id] = true
+			}
+			switch n.Data {
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				if attr(n, "id") == "" {
+					headings = append(headings, n)
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	for _, h := range headings {
+		id := helper.Slugify(textContent(h))
+		if id == "" {
+			continue
+		}
+		unique := id
+		for i := 2; used[unique]; i++ {
+			unique = fmt.Sprintf("%s-%d", id, i)
+		}
+		used[unique] = true
+		h.Attr = append(h.Attr, html.Attribute{Key: "id", Val: unique})
+	}
+	return nil
+}
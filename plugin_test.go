@@ -0,0 +1,68 @@
+package ham
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/net/html"
+)
+
+type recordingPlugin struct {
+	calls []string
+}
+
+func (p *recordingPlugin) Name() string { return "test-recorder" }
+
+func (p *recordingPlugin) BeforeParse(ctx *PageContext, src []byte) ([]byte, error) {
+	p.calls = append(p.calls, "BeforeParse")
+	return src, nil
+}
+
+func (p *recordingPlugin) AfterParsePage(ctx *PageContext, doc *html.Node) error {
+	p.calls = append(p.calls, "AfterParsePage:"+ctx.Page.Layout.Src)
+	return nil
+}
+
+func (p *recordingPlugin) AfterLayout(ctx *PageContext, doc *html.Node) error {
+	p.calls = append(p.calls, "AfterLayout")
+	return nil
+}
+
+func (p *recordingPlugin) BeforeWrite(ctx *PageContext, content []byte) ([]byte, error) {
+	p.calls = append(p.calls, "BeforeWrite:"+ctx.OutPath)
+	return content, nil
+}
+
+func (p *recordingPlugin) AfterBuild(ctx *BuildContext) error {
+	p.calls = append(p.calls, "AfterBuild")
+	return nil
+}
+
+func TestPluginHooks(t *testing.T) {
+	recorder := &recordingPlugin{}
+
+	src := testFS(map[string]string{
+		"ham.json":         `{"plugins": ["test-recorder", "heading-ids"]}`,
+		"src/layout.lhtml": `<html><head><title>T</title></head><body><embed type="ham/page"/></body></html>`,
+		"src/index.html":   `<div data-ham-page-config='{"layout": "layout.lhtml"}'><h1>Hello World</h1><h2 id="keep">Kept</h2><h2>Hello World</h2></div>`,
+	})
+	_, out := buildSite(t, src, Options{Plugins: []Plugin{recorder}})
+
+	want := "BeforeParse,AfterParsePage:layout.lhtml,AfterLayout,BeforeWrite:index.html,AfterBuild"
+	if got := strings.Join(recorder.calls, ","); got != want {
+		t.Errorf("hooks failed: expected %s but got %s", want, got)
+	}
+
+	doc := readDoc(t, out, "index.html")
+	if got := append(attrValues(query(doc, "h1"), "id"), attrValues(query(doc, "h2"), "id")...); !equal(got, "hello-world", "keep", "hello-world-2") {
+		t.Errorf("heading-ids failed: expected the ids hello-world, keep and hello-world-2 but got %q", got)
+	}
+
+	if _, err := NewFS(fstest.MapFS{"ham.json": {Data: []byte(`{"plugins": ["missing"]}`)}}, out, Options{}); err == nil {
+		t.Errorf("expected unknown plugin to be reported")
+	}
+	if _, err := NewFS(src, out, Options{}); err == nil {
+		t.Errorf("expected a plugin of another compiler to be unknown")
+	}
+}
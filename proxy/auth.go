@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"bytes"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	authAttr      = "data-ham-proxy"
+	authAttrValue = "requires-authentication"
+)
+
+// requiresAuthentication reports whether an element of page is marked data-ham-proxy="requires-authentication".
+// the page is tokenized rather than searched, so minified pages with unquoted attributes are recognised too
+func requiresAuthentication(page []byte) bool {
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			for {
+				key, val, more := z.TagAttr()
+				if string(key) == authAttr && string(val) == authAttrValue {
+					return true
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	}
+}
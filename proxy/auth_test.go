@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/fobilow/ham"
+)
+
+func TestRequiresAuthentication(t *testing.T) {
+	src := fstest.MapFS{
+		"ham.json":         {Data: []byte(`{"minify": true}`)},
+		"src/account.html": {Data: []byte(`<div class="account" data-ham-proxy="requires-authentication"><p>private</p></div>`)},
+		"src/index.html":   {Data: []byte(`<p>requires-authentication is public</p>`)},
+	}
+	c, err := ham.NewFS(src, ham.NewMemoryOutput(), ham.Options{})
+	if err != nil {
+		t.Fatalf("new compiler failed: %v", err)
+	}
+
+	for page, want := range map[string]bool{"src/account.html": true, "src/index.html": false} {
+		b, err := c.RenderPage(page)
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		if got := requiresAuthentication(b); got != want {
+			t.Errorf("requires authentication failed: expected %v for %s but got %v", want, b, got)
+		}
+	}
+	if !requiresAuthentication([]byte(`<div data-ham-proxy=requires-authentication>private</div>`)) {
+		t.Errorf("requires authentication failed: expected an unquoted attribute to be recognised")
+	}
+}
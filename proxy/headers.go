@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+)
+
+const headersFile = "_headers"
+
+// pageHeaders returns the headers _headers in the web root sets for urlPath. the file is written by ham build and
+// lists url paths, each followed by indented "Name: value" lines:
+//
+//	/about.html
+//	  Content-Security-Policy: default-src 'self'
+//
+// it is read on every request so a rebuild applies without restarting the proxy
+func pageHeaders(urlPath string) map[string]string {
+	b, err := os.ReadFile(webRoot + "/" + headersFile)
+	if err != nil {
+		return nil
+	}
+
+	var headers map[string]string
+	matched := false
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if trimmed == line { // a url path starts a new block
+			matched = trimmed == urlPath
+			continue
+		}
+		if !matched {
+			continue
+		}
+		i := strings.Index(trimmed, ":")
+		if i <= 0 {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[strings.TrimSpace(trimmed[:i])] = strings.TrimSpace(trimmed[i+1:])
+	}
+	return headers
+}
@@ -122,7 +122,20 @@ func handleWebRequest(c *gin.Context) {
 		ext = ".html"
 		file = "index.html"
 	}
+	if info, err := os.Stat(webRoot + path.Join(dir, file)); ext == "" && (err != nil || info.IsDir()) {
+		// pretty urls: /about is served from about.html or about/index.html
+		if _, err := os.Stat(webRoot + path.Join(dir, file+".html")); err == nil {
+			file += ".html"
+		} else {
+			dir, file = path.Join(dir, file), "index.html"
+		}
+		ext = ".html"
+	}
 
+	urlPath := path.Join(dir, file)
+	if file == "index.html" {
+		urlPath = strings.TrimSuffix(urlPath, "index.html") // index pages are listed in _headers as their directory
+	}
 	file = webRoot + path.Join(dir, file)
 
 	switch ext {
@@ -136,8 +149,7 @@ func handleWebRequest(c *gin.Context) {
 			}
 			return
 		}
-		authPage := strings.Contains(string(b), `data-ham-proxy="requires-authentication"`)
-		if authPage {
+		if requiresAuthentication(b) {
 			// check if user is logged in
 			tokenCookie, err := c.Request.Cookie("access_token")
 			if err != nil || GetSession(tokenCookie.Value).IsInvalid() {
@@ -147,6 +159,9 @@ func handleWebRequest(c *gin.Context) {
 		c.Header("Cache-Control", "max-age=0,no-store,no-cache,must-revalidate")
 		c.Header("Expires", "Thu, 01 Jan 1970 00:00:00 GMT")
 		c.Header("Pragma", "no-store,no-cache")
+		for name, value := range pageHeaders(urlPath) {
+			c.Header(name, value)
+		}
 	}
 
 	log.Println("File:", file)
@@ -0,0 +1,129 @@
+package ham
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"path"
+	"strings"
+)
+
+const (
+	placementHead = "head" // the ham/layout-css slot of the layout
+	placementBody = "body" // the ham/layout-js slot of the layout
+)
+
+// Resource is a stylesheet or script of a page config. it is written as a path, "index.css", or as an object that
+// sets the attributes of its tag, {"src": "https://cdn.example.com/lib.js", "defer": true, "placement": "head"}
+type Resource struct {
+	Src         string `json:"src"`
+	Type        string `json:"type,omitempty"` // module loads a .js file as an es module. .ts files always are
+	Defer       bool   `json:"defer,omitempty"`
+	Async       bool   `json:"async,omitempty"`
+	NoModule    bool   `json:"nomodule,omitempty"`
+	Media       string `json:"media,omitempty"`
+	CrossOrigin string `json:"crossorigin,omitempty"`
+	Integrity   string `json:"integrity,omitempty"`
+
+	// Placement puts the tag in the ham/layout-css slot (head) or the ham/layout-js slot (body).
+	// stylesheets default to head, scripts to body
+	Placement string `json:"placement,omitempty"`
+
+	// Order sorts the resources of a slot, lowest first. resources of the same order keep the order they are declared in,
+	// companion files come after the page config resources of their order
+	Order int `json:"order,omitempty"`
+
+	stylesheet bool // set for the css entries of a page config
+}
+
+func (r *Resource) UnmarshalJSON(b []byte) error {
+	var src string
+	if err := json.Unmarshal(b, &src); err == nil {
+		*r = Resource{Src: src}
+		return nil
+	}
+	type resource Resource // without UnmarshalJSON
+	var res resource
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+	if res.Src == "" {
+		return fmt.Errorf("resource %s has no src", b)
+	}
+	switch res.Placement {
+	case "", placementHead, placementBody:
+	default:
+		return fmt.Errorf("resource %s has an unknown placement %s, use head or body", res.Src, res.Placement)
+	}
+	*r = Resource(res)
+	return nil
+}
+
+func (r Resource) isCSS() bool {
+	return r.stylesheet || path.Ext(r.Src) == ".css"
+}
+
+// placement returns the slot the tag of the resource goes to
+func (r Resource) placement() string {
+	if r.Placement != "" {
+		return r.Placement
+	}
+	if r.isCSS() {
+		return placementHead
+	}
+	return placementBody
+}
+
+// tag returns the <link> or <script> tag of the resource served from url
+func (r Resource) tag(url string) string {
+	var b strings.Builder
+	attr := func(key, val string) {
+		if val != "" {
+			b.WriteString(" " + key + `="` + html.EscapeString(val) + `"`)
+		}
+	}
+	flag := func(key string, set bool) {
+		if set {
+			b.WriteString(" " + key)
+		}
+	}
+
+	if r.isCSS() {
+		b.WriteString(`<link rel="stylesheet"`)
+		attr("href", url)
+		attr("media", r.Media)
+		attr("crossorigin", r.CrossOrigin)
+		attr("integrity", r.Integrity)
+		b.WriteString(">")
+		return b.String()
+	}
+
+	b.WriteString("<script")
+	typ := r.Type
+	if path.Ext(r.Src) == ".ts" {
+		typ = "module"
+	}
+	attr("type", typ)
+	attr("src", url)
+	flag("defer", r.Defer)
+	flag("async", r.Async)
+	flag("nomodule", r.NoModule)
+	attr("crossorigin", r.CrossOrigin)
+	attr("integrity", r.Integrity)
+	b.WriteString("></script>")
+	return b.String()
+}
+
+// asyncTag returns a preload of the stylesheet, turned into a stylesheet by asyncCSSLoader, and a plain stylesheet
+// link for browsers without javascript
+func (r Resource) asyncTag(url string) string {
+	var b strings.Builder
+	b.WriteString(`<link rel="preload" as="style" data-ham-async href="` + html.EscapeString(url) + `"`)
+	for _, a := range [][2]string{{"media", r.Media}, {"crossorigin", r.CrossOrigin}, {"integrity", r.Integrity}} {
+		if a[1] != "" {
+			b.WriteString(" " + a[0] + `="` + html.EscapeString(a[1]) + `"`)
+		}
+	}
+	b.WriteString(">")
+	return b.String() + "<noscript>" + r.tag(url) + "</noscript>"
+}
@@ -0,0 +1,104 @@
+package ham
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/net/html"
+)
+
+func TestNestedPagePaths(t *testing.T) {
+	_, out := buildSite(t, testFS(map[string]string{
+		"src/a/b/page.html":    `<embed type="ham/layout-css"/><embed type="ham/layout-js"/><p>a</p>`,
+		"src/c/b/page.html":    `<embed type="ham/layout-css"/><embed type="ham/layout-js"/><p>c</p>`,
+		"src/mysrc/src/x.html": `<div data-ham-page-config='{"css": ["../../../assets/app/css/x.css"]}'><embed type="ham/layout-css"/></div>`,
+		"src/a/b/page.css":     ``,
+		"src/c/b/page.css":     ``,
+		"src/mysrc/src/x.css":  ``,
+		"src/a/b/page.ts":      ``,
+		"src/c/b/page.ts":      ``,
+		"assets/app/css/x.css": ``,
+	}), Options{})
+
+	tests := []struct {
+		page string
+		css  []string
+		js   []string
+	}{
+		{"a/b/page.html", []string{"/assets/css/a/b/page.css"}, []string{"/assets/js/a/b/page.js"}},
+		{"c/b/page.html", []string{"/assets/css/c/b/page.css"}, []string{"/assets/js/c/b/page.js"}},
+		{"mysrc/src/x.html", []string{"/assets/app/css/x.css", "/assets/css/mysrc/src/x.css"}, []string{}},
+	}
+	for _, test := range tests {
+		doc := readDoc(t, out, test.page)
+		if got := attrValues(query(doc, "link[rel=stylesheet]"), "href"); !equal(got, test.css...) {
+			t.Errorf("nested page paths of %s failed: expected the stylesheets %q but got %q", test.page, test.css, got)
+		}
+		if got := attrValues(query(doc, "script"), "src"); !equal(got, test.js...) {
+			t.Errorf("nested page paths of %s failed: expected the scripts %q but got %q", test.page, test.js, got)
+		}
+	}
+}
+
+func TestMissingResources(t *testing.T) {
+	src := testFS(map[string]string{
+		"src/index.html": `<div data-ham-page-config='{"css": ["missing.css", "/assets/css/site.css"], "js": ["app.js"]}'>` +
+			`<embed type="ham/layout-css"/><embed type="ham/layout-js"/></div>`,
+		"src/app.js":   ``,
+		"src/index.ts": ``,
+	})
+	c, _ := testSite(t, src, Options{})
+
+	doc := renderDoc(t, c, "src/index.html")
+	if got := attrValues(query(doc, "link[rel=stylesheet]"), "href"); !equal(got, "/assets/css/site.css") {
+		t.Errorf("missing resources failed: expected missing.css and index.css to be left out but got %q", got)
+	}
+	if got := attrValues(query(doc, "script"), "src"); !equal(got, "/assets/js/app.js", "/assets/js/index.js") {
+		t.Errorf("missing resources failed: expected the scripts but got %q", got)
+	}
+
+	src["ham.json"] = &fstest.MapFile{Data: []byte(`{"strict": true}`)}
+	c, _ = testSite(t, src, Options{})
+	if _, err := c.RenderPage("src/index.html"); err == nil || !strings.Contains(err.Error(), "src/missing.css not found") {
+		t.Errorf("missing resources failed: expected strict build to fail on src/missing.css but got %v", err)
+	}
+}
+
+func TestResourceAttributes(t *testing.T) {
+	c, _ := testSite(t, testFS(map[string]string{
+		"src/index.html": `<div data-ham-page-config='{
+	"css": ["index.css", {"src": "print.css", "media": "print"}, {"src": "https://fonts.example.com/css?family=Sans", "crossorigin": "anonymous"}],
+	"js": [{"src": "https://cdn.example.com/lib.js", "defer": true, "integrity": "sha384-abc", "crossorigin": "anonymous", "placement": "head", "order": -1},
+		{"src": "legacy.js", "nomodule": true}, {"src": "https://example.com/analytics.js", "async": true, "order": 1}],
+	"js-mod": ["app.js"]
+	}'><embed type="ham/layout-css"/><p>page</p><embed type="ham/layout-js"/></div>`,
+		"src/index.css": ``,
+		"src/print.css": ``,
+		"src/legacy.js": ``,
+		"src/app.js":    ``,
+		"src/index.ts":  ``,
+	}), Options{})
+
+	doc := renderDoc(t, c, "src/index.html")
+	want := []string{
+		`<script src="https://cdn.example.com/lib.js" defer="" crossorigin="anonymous" integrity="sha384-abc"></script>`,
+		`<link rel="stylesheet" href="/assets/css/index.css"/>`,
+		`<link rel="stylesheet" href="/assets/css/print.css" media="print"/>`,
+		`<link rel="stylesheet" href="https://fonts.example.com/css?family=Sans" crossorigin="anonymous"/>`,
+		`<p>page</p>`,
+		`<script src="/assets/js/legacy.js" nomodule=""></script>`,
+		`<script type="module" src="/assets/js/app.js"></script>`,
+		`<script type="module" src="/assets/js/index.js"></script>`,
+		`<script src="https://example.com/analytics.js" async=""></script>`,
+	}
+	var elements []*html.Node
+	for _, n := range children(query(doc, "div")[0]) {
+		if n.Type == html.ElementNode {
+			elements = append(elements, n)
+		}
+	}
+	if got := outerHTML(elements); !equal(got, want...) {
+		t.Errorf("resource attributes failed: expected\n%s\nbut got\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -1,10 +1,13 @@
 package ham
 
 import (
+	"bytes"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode"
 )
 
 const DefaultOutputDir = "./public"
@@ -29,12 +32,12 @@ const defaultLayout = `<!DOCTYPE html>
 </body>
 </html>
 `
-const defaultPage = `<div class="page"
+const pageTemplate = `<div class="page"
 	data-ham-page-config='{
-      "layout": "default.lhtml"
+      "layout": "%s"
      }'
 >
-  <h1>Welcome to HAM</h1>
+  <h1>%s</h1>
 </div>`
 
 const defaultTsConfig = `{
@@ -59,7 +62,7 @@ const defaultPackageJSON = `{
 	  "description": "A HAM Application",
 	  "type": "module",
 	  "scripts": {
-		"build": "ham build && rollup -c",
+		"build": "rollup -c && ham build",
 		"test": "echo \"Error: no test specified\" && exit 1"
 	  },
 	  "devDependencies": {
@@ -72,14 +75,15 @@ const defaultPackageJSON = `{
 	  }
 }`
 
-const defaultGitIgnore = `node_modules`
+const defaultGitIgnore = `node_modules
+.ham-cache`
 const defaultRollupConfig = `import typescript from 'rollup-plugin-typescript2';
 import { nodeResolve } from '@rollup/plugin-node-resolve';
 import copy from 'rollup-plugin-copy';
 import commonjs from '@rollup/plugin-commonjs';
 import {glob} from 'glob';
 
-const inputFiles = glob.sync('./src/*.ts'); // Adjust the pattern as needed
+const inputFiles = glob.sync('./src/**/*.ts'); // Adjust the pattern as needed
 export default {
     input: inputFiles,
     output: {
@@ -92,9 +96,10 @@ export default {
     plugins: [
         copy({
             targets: [
-                {src: 'src/*.css', dest: 'public/assets/css'},
-                {src: 'src/*.js', dest: 'public/assets/js'}
-            ]
+                {src: 'src/**/*.css', dest: 'public/assets/css'},
+                {src: 'src/**/*.js', dest: 'public/assets/js'}
+            ],
+            flatten: false // keep the directories below src, where ham links the files
         }),
         typescript({
             tsconfig: './tsconfig.json'
@@ -138,7 +143,7 @@ func (h *Site) NewProject(siteName, workingDir string) error {
 	}
 
 	// write default index.html
-	if err := createFile(filepath.Join(workingDir, siteName, srcDir, "index.html"), []byte(defaultPage), false); err != nil {
+	if err := createFile(filepath.Join(workingDir, siteName, srcDir, "index.html"), []byte(fmt.Sprintf(pageTemplate, "default.lhtml", "Welcome to HAM")), false); err != nil {
 		return err
 	}
 
@@ -176,20 +181,115 @@ func (h *Site) NewProject(siteName, workingDir string) error {
 	return createFile(filepath.Join(workingDir, siteName, configFileName), []byte(defaultCompileJSON), true)
 }
 
+// NewPage creates the page pagePath, e.g. blog/post, in the src directory of workingDir together with its
+// companion .css and .ts files. the page uses the default layout of the site
+func (h *Site) NewPage(workingDir, pagePath string) error {
+	pagePath = strings.TrimSuffix(filepath.FromSlash(pagePath), ".html")
+	pagePath = strings.TrimPrefix(filepath.Clean(string(filepath.Separator)+pagePath), string(filepath.Separator))
+	if pagePath == "" || pagePath == "." {
+		return fmt.Errorf("please provide the path of the page, e.g. blog/post")
+	}
+	pageFile := filepath.Join(workingDir, srcDir, pagePath+".html")
+	if _, err := os.Stat(pageFile); err == nil {
+		return fmt.Errorf("%s already exists", pageFile)
+	}
+
+	layout, err := filepath.Rel(filepath.Dir(pageFile), filepath.Join(workingDir, srcDir, "default.lhtml"))
+	if err != nil {
+		return err
+	}
+	title := []rune(strings.ReplaceAll(filepath.Base(pagePath), "-", " "))
+	title[0] = unicode.ToUpper(title[0])
+	if err := createFile(pageFile, []byte(fmt.Sprintf(pageTemplate, filepath.ToSlash(layout), string(title))), false); err != nil {
+		return err
+	}
+	if err := createFile(filepath.Join(workingDir, srcDir, pagePath+".css"), []byte(""), false); err != nil {
+		return err
+	}
+	return createFile(filepath.Join(workingDir, srcDir, pagePath+".ts"), []byte(""), false)
+}
+
 func (h *Site) Build(workingDir, outputDir string) error {
-	c, err := New(workingDir, outputDir)
+	return h.BuildWithOptions(workingDir, outputDir, Options{})
+}
+
+func (h *Site) BuildWithOptions(workingDir, outputDir string, opts Options) error {
+	c, err := NewWithOptions(workingDir, outputDir, opts)
 	if err != nil {
 		return err
 	}
 	return c.Compile()
 }
 
+// CheckLinks compiles the site in workingDir in memory and reports the internal links of its pages whose target
+// is neither a compiled page nor an asset in outputDir, stale pages in outputDir do not count. links under the ignore prefixes are skipped
+func (h *Site) CheckLinks(workingDir, outputDir string, opts Options, ignore []string) (*LinkReport, error) {
+	c, err := NewWithOptions(workingDir, outputDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.CheckLinks(ignore)
+}
+
+// Format formats every page, partial and layout in the src directory of workingDir in place
+// and returns the files that changed. files that cannot be formatted safely are reported and left as they are
+func (h *Site) Format(workingDir string) ([]string, error) {
+	var changed []string
+	failed := 0
+	err := filepath.Walk(filepath.Join(workingDir, srcDir), func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch filepath.Ext(filePath) {
+		case ".html", ".phtml", ".lhtml":
+		default:
+			return nil
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		formatted, err := FormatSource(filePath, content)
+		if err != nil {
+			log.Println(err.Error())
+			failed++
+			return nil
+		}
+		if bytes.Equal(content, formatted) {
+			return nil
+		}
+		changed = append(changed, filePath)
+		return os.WriteFile(filePath, formatted, info.Mode())
+	})
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d files could not be formatted", failed)
+	}
+	return changed, err
+}
+
 func (h *Site) Help() string {
 	return `usage: ham <command> [<options>]
 
 The following are supported HAM commands:
   init		Creates a new HAM site
-  build		Compiles HAM site into html website
+  new page <path>	Creates a page in src with its companion .css and .ts files, e.g. ham new page blog/post
+		  -w <dir>	working directory
+  build		Compiles HAM site into html website, after rollup has built its assets (npm run build runs both)
+		  -w <dir>	working directory
+		  --dry-run	compile without writing, list files that would be created, changed or, with --prune, deleted
+		  --diff	like --dry-run, also print a unified diff of every changed page
+		  --prune	delete the pages of the previous build whose source page is gone
+		  --env <name>	apply the ham.json settings of an environment, defaults to $HAM_ENV
+		  --base-path <path>	directory the site is served under, e.g. /docs
+  check links	Checks that the internal links of every compiled page point to a page, file or anchor of the site
+		  -w <dir>	working directory
+		  --env <name>	apply the ham.json settings of an environment, defaults to $HAM_ENV
+		  --base-path <path>	directory the site is served under, e.g. /docs
+		  --ignore <prefixes>	comma separated url prefixes not to check, e.g. /api/
+		  --external	also list the links to other sites, they are never fetched
+  fmt		Formats the pages, partials and layouts of a HAM site in place
+		  -w <dir>	working directory
   version	Displays version of HAM that you are running
 `
 }
@@ -0,0 +1,155 @@
+package ham
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"log"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sitemapFileName = "sitemap.xml"
+	sitemapXmlns    = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	authAttrValue   = "requires-authentication" // data-ham-proxy value of pages the proxy treats as private
+)
+
+// maxSitemapURLs is the most urls a single sitemap may hold. larger sites get a sitemap index
+var maxSitemapURLs = 50000
+
+// SitemapConfig holds the sitemap settings of ham.json
+type SitemapConfig struct {
+	Lastmod string `json:"lastmod,omitempty"` // where lastmod comes from: "mtime" of the source page (default), "git" or "none"
+}
+
+// PageSitemap holds the sitemap settings of a page, e.g. "sitemap": {"priority": 0.8, "changefreq": "weekly"}
+type PageSitemap struct {
+	Exclude    *bool    `json:"exclude,omitempty"` // pages requiring authentication are excluded unless this is false
+	Priority   *float64 `json:"priority,omitempty"`
+	Changefreq string   `json:"changefreq,omitempty"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	Lastmod    string `xml:"lastmod,omitempty"`
+	Changefreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapEntry struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"sitemapindex"`
+	Xmlns    string         `xml:"xmlns,attr"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// writeSitemap writes sitemap.xml for the pages of the build. sites with more than maxSitemapURLs pages get numbered
+// sitemaps and sitemap.xml becomes their index. nothing is written when ham.json has no baseUrl
+func (c *Compiler) writeSitemap() error {
+	if c.config.BaseURL == "" {
+		return nil
+	}
+	baseURL := strings.TrimSuffix(c.config.BaseURL, "/")
+
+	var urls []sitemapURL
+	for _, ctx := range c.pages {
+		var settings PageSitemap
+		if ctx.Page != nil && ctx.Page.Layout.Sitemap != nil {
+			settings = *ctx.Page.Layout.Sitemap
+		}
+		exclude := ctx.requiresAuth
+		if settings.Exclude != nil {
+			exclude = *settings.Exclude
+		}
+		if exclude {
+			continue
+		}
+
+		u := sitemapURL{Loc: baseURL + c.url("/"+pageURLPath(ctx.OutPath)), Changefreq: settings.Changefreq}
+		if settings.Priority != nil {
+			priority, err := sitemapPriority(*settings.Priority)
+			if err != nil {
+				return fmt.Errorf("failed to compile %s. %v", ctx.SrcPath, err)
+			}
+			u.Priority = priority
+		}
+		if lastmod := c.lastmod(ctx.SrcPath); !lastmod.IsZero() {
+			u.Lastmod = lastmod.UTC().Format(time.RFC3339)
+		}
+		urls = append(urls, u)
+	}
+	sort.Slice(urls, func(i, j int) bool { return urls[i].Loc < urls[j].Loc })
+
+	if len(urls) <= maxSitemapURLs {
+		return c.writeXML(sitemapFileName, sitemapURLSet{Xmlns: sitemapXmlns, URLs: urls})
+	}
+
+	index := sitemapIndex{Xmlns: sitemapXmlns}
+	for i := 0; i*maxSitemapURLs < len(urls); i++ {
+		end := (i + 1) * maxSitemapURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		name := fmt.Sprintf("sitemap-%d.xml", i+1)
+		if err := c.writeXML(name, sitemapURLSet{Xmlns: sitemapXmlns, URLs: urls[i*maxSitemapURLs : end]}); err != nil {
+			return err
+		}
+		index.Sitemaps = append(index.Sitemaps, sitemapEntry{Loc: baseURL + c.url("/"+name)})
+	}
+	return c.writeXML(sitemapFileName, index)
+}
+
+// sitemapPriority writes a priority as it is configured, with at least one decimal, e.g. 1.0, 0.25
+func sitemapPriority(p float64) (string, error) {
+	if p < 0 || p > 1 {
+		return "", fmt.Errorf("sitemap priority %v is not between 0.0 and 1.0", p)
+	}
+	s := strconv.FormatFloat(p, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s, nil
+}
+
+func (c *Compiler) writeXML(name string, v interface{}) error {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return c.writePage(name, append([]byte(xml.Header), append(b, '\n')...))
+}
+
+// lastmod returns when the source page last changed, according to the sitemap settings of ham.json
+func (c *Compiler) lastmod(srcPath string) time.Time {
+	switch c.config.Sitemap.Lastmod {
+	case "none":
+		return time.Time{}
+	case "git":
+		if c.workingDir != "" {
+			out, err := exec.Command("git", "-C", c.workingDir, "log", "-1", "--format=%cI", "--", srcPath).Output()
+			if t, perr := time.Parse(time.RFC3339, strings.TrimSpace(string(out))); err == nil && perr == nil {
+				return t
+			}
+		}
+		log.Println("no git history for " + srcPath + ", using file modification time")
+	}
+
+	info, err := fs.Stat(c.src, srcPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
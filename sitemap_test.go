@@ -0,0 +1,72 @@
+package ham
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// readSitemap parses a sitemap of the output
+func readSitemap(t *testing.T, out *MemoryOutput, name string) sitemapURLSet {
+	t.Helper()
+	b, err := out.ReadFile(name)
+	if err != nil {
+		t.Fatalf("%s not written: %v", name, err)
+	}
+	var urls sitemapURLSet
+	if err := xml.Unmarshal(b, &urls); err != nil || !bytes.HasPrefix(b, []byte(xml.Header)) {
+		t.Fatalf("%s is not a sitemap: %v\n%s", name, err, b)
+	}
+	return urls
+}
+
+func TestSitemap(t *testing.T) {
+	src := testFS(map[string]string{
+		"ham.json":            `{"baseUrl": "https://example.com/"}`,
+		"src/index.html":      `<p>home</p>`,
+		"src/about.html":      "---\nsitemap:\n  priority: 0.25\n  changefreq: monthly\n---\n<p>about</p>",
+		"src/account.html":    `<div data-ham-proxy="requires-authentication">account</div>`,
+		"src/draft.html":      `<div data-ham-page-config='{"sitemap": {"exclude": true}}'>draft</div>`,
+		"src/blog/index.html": "---\nsitemap:\n  priority: 1\n---\n<p>blog</p>",
+	})
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"src/index.html", "src/about.html", "src/blog/index.html"} {
+		src[name].ModTime = modTime
+	}
+	c, out := buildSite(t, src, Options{})
+
+	want := []sitemapURL{
+		{Loc: "https://example.com/", Lastmod: "2024-03-01T12:00:00Z"},
+		{Loc: "https://example.com/about.html", Lastmod: "2024-03-01T12:00:00Z", Changefreq: "monthly", Priority: "0.25"},
+		{Loc: "https://example.com/blog/", Lastmod: "2024-03-01T12:00:00Z", Priority: "1.0"},
+	}
+	if got := readSitemap(t, out, "sitemap.xml"); got.Xmlns != sitemapXmlns || !reflect.DeepEqual(got.URLs, want) {
+		t.Errorf("sitemap failed: expected\n%+v\nbut got\n%+v", want, got.URLs)
+	}
+
+	defer func(max int) { maxSitemapURLs = max }(maxSitemapURLs)
+	maxSitemapURLs = 2
+	if err := c.Compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	b, _ := out.ReadFile("sitemap.xml")
+	var index sitemapIndex
+	if err := xml.Unmarshal(b, &index); err != nil {
+		t.Fatalf("sitemap index failed: %v\n%s", err, b)
+	}
+	wantIndex := []sitemapEntry{{Loc: "https://example.com/sitemap-1.xml"}, {Loc: "https://example.com/sitemap-2.xml"}}
+	if !reflect.DeepEqual(index.Sitemaps, wantIndex) {
+		t.Errorf("sitemap index failed: expected %v but got %v", wantIndex, index.Sitemaps)
+	}
+	if got := readSitemap(t, out, "sitemap-2.xml"); !reflect.DeepEqual(got.URLs, want[2:]) {
+		t.Errorf("sitemap index failed: expected sitemap-2.xml to hold %v but got %v", want[2:], got.URLs)
+	}
+
+	src["src/about.html"] = &fstest.MapFile{Data: []byte("---\nsitemap:\n  priority: 1.5\n---\n<p>about</p>")}
+	if err := c.Compile(); err == nil || err.Error() != "failed to compile src/about.html. sitemap priority 1.5 is not between 0.0 and 1.0" {
+		t.Errorf("sitemap failed: expected a priority above 1 to be an error but got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package ham
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// remoteTimeout bounds the download of a remote resource
+var remoteTimeout = 30 * time.Second
+
+// maxRemoteSize bounds the size of a remote resource, larger files are an error
+var maxRemoteSize int64 = 10 << 20
+
+// resourceIntegrity returns the subresource integrity of res, the sha384 digest of the file browsers will load.
+// remote resources are downloaded once per build
+func (c *Compiler) resourceIntegrity(res Resource) (string, error) {
+	c.mu.Lock()
+	digest, ok := c.integrity[res.Src]
+	c.mu.Unlock()
+	if ok {
+		return digest, nil
+	}
+
+	b, err := c.resourceContent(res)
+	if err != nil {
+		return "", err
+	}
+
+	digest = integrity(b)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.integrity == nil {
+		c.integrity = make(map[string]string)
+	}
+	c.integrity[res.Src] = digest
+	return digest, nil
+}
+
+// integrity returns the sha384 subresource integrity of b
+func integrity(b []byte) string {
+	sum := sha512.Sum384(b)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// resourceContent returns the file browsers load for res. local resources are read from the output, where rollup
+// puts them, falling back to the .css and .js files of the project it copies as they are
+func (c *Compiler) resourceContent(res Resource) ([]byte, error) {
+	if strings.Contains(res.Src, "://") {
+		return fetch(res.Src)
+	}
+	b, err := c.out.ReadFile(strings.TrimPrefix(assetURL(res.Src), "/"))
+	if err != nil && !path.IsAbs(res.Src) && path.Ext(res.Src) != ".ts" {
+		b, err = fs.ReadFile(c.src, res.Src)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in the output, run rollup before ham build (npm run build runs both)", assetURL(res.Src))
+	}
+	return b, nil
+}
+
+func fetch(url string) ([]byte, error) {
+	client := http.Client{Timeout: remoteTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxRemoteSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, maxRemoteSize)
+	}
+	return b, nil
+}
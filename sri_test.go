@@ -0,0 +1,84 @@
+package ham
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/net/html"
+)
+
+func TestSubresourceIntegrity(t *testing.T) {
+	digest := func(s string) string {
+		sum := sha512.Sum384([]byte(s))
+		return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lib.js" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("lib()"))
+	}))
+	defer server.Close()
+
+	page := `<div data-ham-page-config='{"js": [{"src": "` + server.URL + `/lib.js", "crossorigin": "use-credentials"}, {"src": "pinned.js", "integrity": "sha384-pinned"}]}'>` +
+		`<embed type="ham/layout-css"/><embed type="ham/layout-js"/></div>`
+	src := testFS(map[string]string{
+		"ham.json":       `{"sri": true}`,
+		"src/index.html": page,
+		"src/index.css":  `body{}`,
+		"src/index.ts":   `const a: number = 1`,
+		"src/pinned.js":  `pinned()`,
+	})
+	c, out := testSite(t, src, Options{})
+	out.WriteFile("assets/js/index.js", []byte(`const a = 1`))
+	// resources lists the url, crossorigin and integrity of the stylesheets and scripts of a page
+	resources := func(doc *html.Node) []string {
+		var got []string
+		for _, n := range append(query(doc, "link[rel=stylesheet]"), query(doc, "script")...) {
+			got = append(got, attrOr(n, "href", attr(n, "src"))+" "+attr(n, "crossorigin")+" "+attr(n, "integrity"))
+		}
+		return got
+	}
+
+	want := []string{
+		"/assets/css/index.css anonymous " + digest(`body{}`),
+		server.URL + "/lib.js use-credentials " + digest(`lib()`),
+		"/assets/js/pinned.js  sha384-pinned",
+		"/assets/js/index.js anonymous " + digest(`const a = 1`),
+	}
+	if got := resources(renderDoc(t, c, "src/index.html")); !equal(got, want...) {
+		t.Errorf("subresource integrity failed: expected\n%s\nbut got\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	// a page rendered after a build hashes the scripts rollup wrote since
+	if err := c.Compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	out.WriteFile("assets/js/index.js", []byte(`const a = 2`))
+	want[3] = "/assets/js/index.js anonymous " + digest(`const a = 2`)
+	if got := resources(renderDoc(t, c, "src/index.html")); !equal(got, want...) {
+		t.Errorf("subresource integrity failed: expected after the build\n%s\nbut got\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	src["ham.json"] = &fstest.MapFile{Data: []byte(`{"sri": true, "strict": true}`)}
+	c, _ = testSite(t, src, Options{})
+	if _, err := c.RenderPage("src/index.html"); err == nil || !strings.Contains(err.Error(), "run rollup before ham build") {
+		t.Errorf("subresource integrity failed: expected strict build to fail without compiled scripts but got %v", err)
+	}
+
+	defer func(size int64) { maxRemoteSize = size }(maxRemoteSize)
+	maxRemoteSize = 4
+	if _, err := fetch(server.URL + "/lib.js"); err == nil || !strings.Contains(err.Error(), "lib.js is larger than 4 bytes") {
+		t.Errorf("subresource integrity failed: expected a remote file over the limit to be an error but got %v", err)
+	}
+	maxRemoteSize = 5
+	if b, err := fetch(server.URL + "/lib.js"); err != nil || string(b) != "lib()" {
+		t.Errorf("subresource integrity failed: expected a remote file at the limit to be read but got %q (%v)", b, err)
+	}
+}
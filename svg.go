@@ -0,0 +1,168 @@
+package ham
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func init() {
+	RegisterEmbedType("ham/svg", embedSVG)
+}
+
+var svgURLRef = regexp.MustCompile(`url\(\s*['"]?#([^'")\s]+)['"]?\s*\)`)
+
+// svgStyleRef matches the #id references of a <style>, both #id selectors and url(#id)
+var svgStyleRef = regexp.MustCompile(`#((?:[\w-]|[^\x00-\x7f])+)`)
+
+// embedSVG inlines the svg file in src, e.g. <embed type="ham/svg" src="icons/search.svg" class="icon" title="Search">.
+// class, width, height and aria-* attributes on the embed are copied to the <svg>, title becomes its <title>.
+// ids inside the svg are made unique when more than one svg is inlined on a page
+func embedSVG(ctx *EmbedContext) ([]byte, error) {
+	b, err := ctx.ReadFile(ctx.Embed.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := html.ParseFragment(bytes.NewReader(b), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return nil, err
+	}
+	var svg *html.Node
+	for _, n := range nodes {
+		if n.Type == html.ElementNode && n.Data == "svg" {
+			svg = n
+			break
+		}
+	}
+	if svg == nil {
+		return nil, fmt.Errorf("%s is not an svg file", ctx.Resolve(ctx.Embed.Src))
+	}
+	removeComments(svg)
+
+	keys := make([]string, 0, len(ctx.Embed.Attrs))
+	for key := range ctx.Embed.Attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		val := ctx.Embed.Attrs[key]
+		switch {
+		case key == "class", key == "width", key == "height", strings.HasPrefix(key, "aria-"):
+			setAttr(svg, key, val)
+		case key == "title":
+			setSVGTitle(svg, val)
+			if attr(svg, "role") == "" {
+				setAttr(svg, "role", "img")
+			}
+		}
+	}
+
+	if ctx.Index > 0 {
+		dedupeSVGIds(svg, fmt.Sprintf("-%d", ctx.Index))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := html.Render(buf, svg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// removeComments drops comments, including the xml prologue which the html parser reads as a comment
+func removeComments(n *html.Node) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.CommentNode {
+			n.RemoveChild(child)
+		} else {
+			removeComments(child)
+		}
+		child = next
+	}
+}
+
+func setSVGTitle(svg *html.Node, title string) {
+	for child := svg.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "title" {
+			svg.RemoveChild(child)
+			break
+		}
+	}
+	t := &html.Node{Type: html.ElementNode, Data: "title", Namespace: "svg"}
+	t.AppendChild(&html.Node{Type: html.TextNode, Data: title})
+	svg.InsertBefore(t, svg.FirstChild)
+}
+
+// dedupeSVGIds appends suffix to every id in the svg and rewrites the references to them
+func dedupeSVGIds(svg *html.Node, suffix string) {
+	ids := make(map[string]bool)
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id := attr(n, "id"); id != "" {
+				ids[id] = true
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(svg)
+	if len(ids) == 0 {
+		return
+	}
+
+	rewriteURLs := func(s string) string {
+		return svgURLRef.ReplaceAllStringFunc(s, func(m string) string {
+			id := svgURLRef.FindStringSubmatch(m)[1]
+			if !ids[id] {
+				return m
+			}
+			return "url(#" + id + suffix + ")"
+		})
+	}
+
+	var rewrite func(n *html.Node)
+	rewrite = func(n *html.Node) {
+		switch n.Type {
+		case html.ElementNode:
+			for i, a := range n.Attr {
+				switch {
+				case a.Key == "id" && ids[a.Val]:
+					n.Attr[i].Val = a.Val + suffix
+				case a.Key == "href" && strings.HasPrefix(a.Val, "#") && ids[a.Val[1:]]:
+					n.Attr[i].Val = a.Val + suffix
+				case a.Key == "aria-labelledby", a.Key == "aria-describedby":
+					refs := strings.Fields(a.Val)
+					for j, ref := range refs {
+						if ids[ref] {
+							refs[j] = ref + suffix
+						}
+					}
+					n.Attr[i].Val = strings.Join(refs, " ")
+				default:
+					n.Attr[i].Val = rewriteURLs(a.Val)
+				}
+			}
+		case html.TextNode:
+			if n.Parent != nil && n.Parent.Data == "style" {
+				n.Data = svgStyleRef.ReplaceAllStringFunc(n.Data, func(m string) string {
+					if !ids[m[1:]] {
+						return m
+					}
+					return m + suffix
+				})
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			rewrite(child)
+		}
+	}
+	rewrite(svg)
+}
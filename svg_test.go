@@ -0,0 +1,63 @@
+package ham
+
+import (
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestSVGEmbed(t *testing.T) {
+	c, _ := testSite(t, testFS(map[string]string{
+		"src/icons/star.svg": `<?xml version="1.0" encoding="UTF-8"?>
+<!-- generator comment -->
+<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24" width="24"><style>#g{color:red}#gx,#g-a{fill:url(#g)}</style><defs><linearGradient id="g"></linearGradient></defs><path fill="url(#g)" d="M0 0h24v24H0z"/><use href="#g"/></svg>`,
+		"src/index.html": `<embed type="ham/svg" src="icons/star.svg" class="icon" width="16" aria-hidden="true"/><embed type="ham/svg" src="icons/star.svg" title="Star"/>`,
+	}), Options{})
+
+	doc := renderDoc(t, c, "src/index.html")
+	svgs := query(doc, "svg")
+	if len(svgs) != 2 {
+		t.Fatalf("svg embed failed: expected 2 inline svgs but got %d", len(svgs))
+	}
+	tests := []struct {
+		attrs []string
+		title string
+	}{
+		{attrs: []string{"xmlns=http://www.w3.org/2000/svg", "viewBox=0 0 24 24", "width=16", "aria-hidden=true", "class=icon"}},
+		{attrs: []string{"xmlns=http://www.w3.org/2000/svg", "viewBox=0 0 24 24", "width=24", "role=img"}, title: "Star"},
+	}
+	for i, test := range tests {
+		var attrs []string
+		for _, a := range svgs[i].Attr {
+			attrs = append(attrs, a.Key+"="+a.Val)
+		}
+		if !equal(attrs, test.attrs...) {
+			t.Errorf("svg embed %d failed: expected the attributes %q but got %q", i, test.attrs, attrs)
+		}
+		if test.title == "" {
+			continue
+		}
+		if title := svgs[i].FirstChild; title == nil || title.Data != "title" || textContent(title) != test.title {
+			t.Errorf("svg embed %d failed: expected the title %s first", i, test.title)
+		}
+	}
+
+	// the ids of the second copy are renamed, with every reference to them
+	if got := attrValues(query(doc, "linearGradient"), "id"); !equal(got, "g", "g-1") {
+		t.Errorf("svg embed failed: expected the ids g and g-1 but got %q", got)
+	}
+	if got := attrValues(query(doc, "path"), "fill"); !equal(got, "url(#g)", "url(#g-1)") {
+		t.Errorf("svg embed failed: expected the fills url(#g) and url(#g-1) but got %q", got)
+	}
+	if got := attrValues(query(doc, "use"), "href"); !equal(got, "#g", "#g-1") {
+		t.Errorf("svg embed failed: expected the references #g and #g-1 but got %q", got)
+	}
+	if got := texts(query(doc, "style")); !equal(got, "#g{color:red}#gx,#g-a{fill:url(#g)}", "#g-1{color:red}#gx,#g-a{fill:url(#g-1)}") {
+		t.Errorf("svg embed failed: expected the style of the second copy to use g-1 but got %q", got)
+	}
+	walkNodes(doc, func(n *html.Node) {
+		if n.Type == html.CommentNode {
+			t.Errorf("svg embed failed: prologue and comments not stripped, got <!--%s-->", n.Data)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package ham
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/fobilow/ham/helper"
+)
+
+// TaxonomyConfig generates a page for every value of a front matter key across a collection, e.g.
+// "taxonomies": {"tags": {"collection": "blog", "template": "src/blog/tag.thtml", "index": "src/blog/tags.thtml"}}
+type TaxonomyConfig struct {
+	Collection string `json:"collection"`
+	Key        string `json:"key,omitempty"`   // front matter key holding the terms. defaults to the taxonomy name
+	Template   string `json:"template"`        // page rendered for every term
+	Index      string `json:"index,omitempty"` // page listing all terms
+	Path       string `json:"path,omitempty"`  // output directory of the pages. defaults to the taxonomy name
+}
+
+// virtualPage is a page without a source file of its own, rendered from a template, e.g. the page of a tag
+type virtualPage struct {
+	Template string
+	OutPath  string
+	Values   map[string]string // filled into __key__ replacement keys of the template
+	items    []*collectionItem // listed by ham/collection embeds of the template
+}
+
+// compileVirtualPages renders and writes pages generated by the build
+func (c *Compiler) compileVirtualPages(pages []virtualPage) error {
+	for _, vp := range pages {
+		src := c.readFile(vp.Template)
+		if src == nil {
+			return fmt.Errorf("template %s not found", vp.Template)
+		}
+		var pairs []string
+		for key, val := range vp.Values {
+			pairs = append(pairs, embedReplaceKey(key), html.EscapeString(val))
+		}
+		if len(pairs) > 0 {
+			src = []byte(strings.NewReplacer(pairs...).Replace(string(src)))
+		}
+
+		ctx := &PageContext{SrcPath: vp.Template, OutPath: vp.OutPath, items: vp.items}
+		pageHTML, err := c.render(ctx, src)
+		if err != nil {
+			return err
+		}
+		if err := c.checkOutPath(ctx); err != nil {
+			return err
+		}
+		log.Println("Creating page: " + vp.OutPath + " from " + vp.Template)
+		if err := c.writePage(vp.OutPath, pageHTML); err != nil {
+			return err
+		}
+		c.pages = append(c.pages, ctx)
+	}
+	return nil
+}
+
+// taxonomyPages returns the term pages and index pages of every taxonomy of ham.json
+func (c *Compiler) taxonomyPages() ([]virtualPage, error) {
+	var names []string
+	for name := range c.config.Taxonomies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pages []virtualPage
+	for _, name := range names {
+		conf := c.config.Taxonomies[name]
+		terms, err := c.taxonomy(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, term := range terms {
+			pages = append(pages, virtualPage{
+				Template: conf.Template,
+				OutPath:  path.Join(taxonomyPath(name, conf), term.Data["slug"].(string), "index.html"),
+				Values:   map[string]string{"term": term.Title, "count": stringValue(term.Data["count"])},
+				items:    term.items,
+			})
+		}
+		if conf.Index != "" {
+			pages = append(pages, virtualPage{Template: conf.Index, OutPath: path.Join(taxonomyPath(name, conf), "index.html"), items: terms})
+		}
+	}
+	return pages, nil
+}
+
+// taxonomy returns the terms of a taxonomy sorted by slug. every term is an item linking to the page of the term,
+// holding its slug and count in Data. terms with the same slug, e.g. Go and go, are one term spelled as first found
+func (c *Compiler) taxonomy(name string) ([]*collectionItem, error) {
+	conf, ok := c.config.Taxonomies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown taxonomy %s", name)
+	}
+	items, err := c.collection(conf.Collection)
+	if err != nil {
+		return nil, fmt.Errorf("taxonomy %s: %v", name, err)
+	}
+	key := conf.Key
+	if key == "" {
+		key = name
+	}
+
+	bySlug := make(map[string]*collectionItem)
+	var terms []*collectionItem
+	for _, item := range items {
+		var values []interface{}
+		switch v := item.Data[key].(type) {
+		case []interface{}:
+			values = v
+		case nil:
+		default:
+			values = []interface{}{v}
+		}
+		for _, v := range values {
+			term := strings.TrimSpace(stringValue(v))
+			slug := helper.Slugify(term)
+			if slug == "" {
+				continue
+			}
+			t, ok := bySlug[slug]
+			if !ok {
+				t = &collectionItem{
+					Title: term,
+					URL:   c.url("/" + path.Join(taxonomyPath(name, conf), slug) + "/"),
+					Data:  map[string]interface{}{"slug": slug, "term": term},
+				}
+				bySlug[slug] = t
+				terms = append(terms, t)
+			}
+			t.items = append(t.items, item)
+			t.Data["count"] = float64(len(t.items))
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool { return terms[i].Data["slug"].(string) < terms[j].Data["slug"].(string) })
+	return terms, nil
+}
+
+func taxonomyPath(name string, conf TaxonomyConfig) string {
+	if p := strings.Trim(conf.Path, "/"); p != "" {
+		return p
+	}
+	return name
+}
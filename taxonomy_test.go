@@ -0,0 +1,38 @@
+package ham
+
+import "testing"
+
+func TestTaxonomyPages(t *testing.T) {
+	_, out := buildSite(t, testFS(map[string]string{
+		"ham.json": `{"collections": {"blog": {"glob": "src/blog/*.md"}},
+			"taxonomies": {"tags": {"collection": "blog", "template": "src/blog/tag.thtml", "index": "src/blog/tags.thtml"}}}`,
+		"src/blog/a.md":       "---\ntitle: A\ndate: 2024-01-01\ntags: [Go, Web Dev]\n---\na",
+		"src/blog/b.md":       "---\ntitle: B\ndate: 2024-02-01\ntags: Go\n---\nb",
+		"src/blog/tag.thtml":  "---\ntitle: Posts tagged __term__\n---\n<h1>__term__ (__count__)</h1><ul><embed type=\"ham/collection\" src=\"item.phtml\"/></ul>",
+		"src/blog/tags.thtml": `<ul><embed type="ham/collection" src="item.phtml"/></ul>`,
+		"src/blog/item.phtml": `<li><a href="__url__">__title__</a></li>`,
+	}), Options{})
+
+	tests := []struct {
+		page    string
+		title   []string
+		heading []string
+		links   []string
+	}{
+		{"tags/go/index.html", []string{"Posts tagged Go"}, []string{"Go (2)"}, []string{"B /blog/b.html", "A /blog/a.html"}},
+		{"tags/web-dev/index.html", []string{"Posts tagged Web Dev"}, []string{"Web Dev (1)"}, []string{"A /blog/a.html"}},
+		{"tags/index.html", []string{}, []string{}, []string{"Go /tags/go/", "Web Dev /tags/web-dev/"}},
+	}
+	for _, test := range tests {
+		doc := readDoc(t, out, test.page)
+		if got := texts(query(doc, "title")); !equal(got, test.title...) {
+			t.Errorf("taxonomy page %s failed: expected the title %q but got %q", test.page, test.title, got)
+		}
+		if got := texts(query(doc, "h1")); !equal(got, test.heading...) {
+			t.Errorf("taxonomy page %s failed: expected the heading %q but got %q", test.page, test.heading, got)
+		}
+		if got := links(doc); !equal(got, test.links...) {
+			t.Errorf("taxonomy page %s failed: expected the links %q but got %q", test.page, test.links, got)
+		}
+	}
+}